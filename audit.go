@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// auditLogPath is the JSON-lines file every step is recorded in; set by
+// -audit-log. Empty disables auditing (the default in tests).
+var auditLogPath string
+
+// Audit record events. A command that is allowed gets a started record
+// before it runs and a finished one after, so a command interrupted by a
+// crash still shows up; a refused one gets a single denied record.
+const (
+	AuditStarted  = "started"
+	AuditFinished = "finished"
+	AuditDenied   = "denied"
+)
+
+// AuditRecord is one line of the audit log. ExitCode is -1 when the command
+// hasn't run (yet) to completion; Denied is set when the policy refused it.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	RunID      string    `json:"run_id"`
+	Argv       []string  `json:"argv"`
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+	Denied     string    `json:"denied,omitempty"`
+}
+
+// newRunID returns a random identifier for one procedure run.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// writeAudit appends rec to the audit log. Each record is written with a
+// single write on an O_APPEND descriptor so lines are never interleaved or
+// overwritten.
+func writeAudit(rec AuditRecord) error {
+	if auditLogPath == "" {
+		return nil
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(auditLogPath), 0o750); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	f, err := os.OpenFile(auditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("audit log: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	return nil
+}
+
+// beginAudit checks checkArgv, the command before elevation, against the
+// policy and records argv, the command as executed in run runID, as started
+// or denied.
+// The command must only run when beginAudit returns nil: an unwritable audit
+// log keeps it from running unrecorded.
+func beginAudit(runID string, checkArgv, argv []string) (AuditRecord, error) {
+	rec := AuditRecord{Time: time.Now().UTC(), Event: AuditStarted, RunID: runID, Argv: argv, ExitCode: -1}
+	if err := policy.Check(checkArgv); err != nil {
+		var denied *PolicyError
+		if errors.As(err, &denied) {
+			rec.Denied = denied.Reason
+		}
+		rec.Event = AuditDenied
+		if auditErr := writeAudit(rec); auditErr != nil {
+			fmt.Println("Writing audit record failed:", auditErr)
+		}
+		return rec, err
+	}
+	return rec, writeAudit(rec)
+}
+
+// finishAudit records the outcome of a command begun with beginAudit and
+// returns the finished record. The command has already run, so a failed
+// write is only reported.
+func finishAudit(rec AuditRecord, err error) AuditRecord {
+	rec.Event, rec.ExitCode = AuditFinished, exitCode(err)
+	rec.DurationMS = time.Since(rec.Time).Milliseconds()
+	rec.Time = time.Now().UTC()
+	if auditErr := writeAudit(rec); auditErr != nil {
+		fmt.Println("Writing audit record failed:", auditErr)
+	}
+	return rec
+}
+
+// auditedOutput runs argv via ExecOutput, for commands whose output a
+// procedure needs, under the same policy check and audit as runCommand.
+func auditedOutput(runID string, checkArgv, argv []string) ([]byte, error) {
+	rec, err := beginAudit(runID, checkArgv, argv)
+	if err != nil {
+		return nil, err
+	}
+	out, err := ExecOutput(argv[0], argv[1:]...)
+	finishAudit(rec, err)
+	return out, err
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAuditLog decodes every line of the audit log at path.
+func readAuditLog(t *testing.T, path string) []AuditRecord {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recs []AuditRecord
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// ===================== runCommand policy and audit =====================
+func TestRunCommand_PolicyAndAudit(t *testing.T) {
+	oldRun, oldPolicy, oldPath, oldLookPath, oldElevation := RunArgv, policy, auditLogPath, LookPath, elevation
+	defer func() {
+		RunArgv, policy, auditLogPath, LookPath, elevation = oldRun, oldPolicy, oldPath, oldLookPath, oldElevation
+	}()
+
+	tests := []struct {
+		name         string
+		step         Step
+		runErr       error
+		wantRan      bool
+		wantExitCode int
+		wantDenied   string
+		wantEvents   []string
+		unwritable   bool
+		success      bool
+	}{
+		{
+			name:       "success: allowed step is recorded as started, then finished with its elevation",
+			step:       privCmd("umount", "/mnt/disk1"),
+			wantRan:    true,
+			wantEvents: []string{AuditStarted, AuditFinished},
+			success:    true,
+		},
+		{
+			name:         "failure: allowed step that fails",
+			step:         cmd("cat", "/mnt/disk1/test.txt"),
+			runErr:       errors.New("command failed"),
+			wantRan:      true,
+			wantExitCode: -1,
+			wantEvents:   []string{AuditStarted, AuditFinished},
+			success:      false,
+		},
+		{
+			name:         "failure: denied step never runs",
+			step:         privCmd("rm", "-rf", "/"),
+			wantExitCode: -1,
+			wantDenied:   "arguments do not match any rule for /usr/bin/rm",
+			wantEvents:   []string{AuditDenied},
+			success:      false,
+		},
+		{
+			name:       "failure: step never runs unrecorded",
+			step:       privCmd("umount", "/mnt/disk1"),
+			unwritable: true,
+			success:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditLogPath = filepath.Join(t.TempDir(), "log", "audit.jsonl")
+			if tt.unwritable {
+				os.WriteFile(filepath.Dir(auditLogPath), nil, 0o644) // a file where the directory should be
+			}
+			m := newRunManager()
+			run, _ := m.Start("disk", "schedule")
+			policy = defaultPolicy()
+			elevation = elevations["sudo"]
+			LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+			ran := false
+			RunArgv = func(argv []string, _ *Recorder) error {
+				ran = true
+				return tt.runErr
+			}
+
+			err := runCommand(tt.step, m.Recorder(run))
+			if ran != tt.wantRan {
+				t.Errorf("RunArgv called = %v, want %v", ran, tt.wantRan)
+			}
+			var denied *PolicyError
+			if isDenied := errors.As(err, &denied); isDenied != (tt.wantDenied != "") {
+				t.Errorf("runCommand() = %v, want policy error %v", err, tt.wantDenied != "")
+			}
+			if (err == nil) != tt.success {
+				t.Errorf("runCommand() = %v, want success %v", err, tt.success)
+			}
+			if tt.unwritable {
+				return
+			}
+
+			recs := readAuditLog(t, auditLogPath)
+			var events []string
+			for _, rec := range recs {
+				events = append(events, rec.Event)
+				if want := stepArgv(tt.step); rec.RunID != run.ID || rec.Time.IsZero() || !reflect.DeepEqual(rec.Argv, want) {
+					t.Errorf("audit record = %+v, want run %s and argv %q", rec, run.ID, want)
+				}
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Fatalf("audit events = %v, want %v", events, tt.wantEvents)
+			}
+			if last := recs[len(recs)-1]; last.ExitCode != tt.wantExitCode || last.Denied != tt.wantDenied {
+				t.Errorf("last audit record = %+v, want exit code %d, denied %q", last, tt.wantExitCode, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestRunCommand_AuditsRealExitCode(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	oldPath := auditLogPath
+	defer func() { auditLogPath = oldPath }()
+	auditLogPath = filepath.Join(t.TempDir(), "audit.jsonl")
+
+	if err := runCommand(shellCmd("exit 3"), nil); err == nil {
+		t.Fatal("runCommand() expected error, got nil")
+	}
+	if err := runCommand(cmd("true"), nil); err != nil {
+		t.Fatalf("runCommand() unexpected error: %v", err)
+	}
+	recs := readAuditLog(t, auditLogPath)
+	if len(recs) != 4 || recs[0].ExitCode != -1 || recs[1].ExitCode != 3 || recs[3].ExitCode != 0 {
+		t.Errorf("audit records = %+v, want started and finished records with exit codes 3 then 0 appended", recs)
+	}
+}
+
+// ===================== auditedOutput =====================
+func TestAuditedOutput(t *testing.T) {
+	oldExec, oldPolicy, oldPath, oldLookPath := ExecOutput, policy, auditLogPath, LookPath
+	defer func() { ExecOutput, policy, auditLogPath, LookPath = oldExec, oldPolicy, oldPath, oldLookPath }()
+
+	tests := []struct {
+		name       string
+		checkArgv  []string
+		argv       []string
+		wantRan    bool
+		wantEvents []string
+		success    bool
+	}{
+		{name: "success: losetup -f is allowed and audited", checkArgv: []string{"losetup", "-f"}, argv: []string{"sudo", "-n", "losetup", "-f"}, wantRan: true, wantEvents: []string{AuditStarted, AuditFinished}, success: true},
+		{name: "success: the elevation probe is allowed and audited", checkArgv: []string{"true"}, argv: []string{"sudo", "-n", "true"}, wantRan: true, wantEvents: []string{AuditStarted, AuditFinished}, success: true},
+		{name: "failure: other commands are denied", checkArgv: []string{"losetup", "-a"}, argv: []string{"sudo", "-n", "losetup", "-a"}, wantEvents: []string{AuditDenied}, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditLogPath = filepath.Join(t.TempDir(), "audit.jsonl")
+			policy = defaultPolicy()
+			LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+			ran := false
+			ExecOutput = func(name string, arg ...string) ([]byte, error) {
+				ran = true
+				return []byte("/dev/loop7\n"), nil
+			}
+
+			_, err := auditedOutput("run-1", tt.checkArgv, tt.argv)
+			if (err == nil) != tt.success || ran != tt.wantRan {
+				t.Errorf("auditedOutput() = %v, ran %v, want success %v", err, ran, tt.success)
+			}
+			var events []string
+			for _, rec := range readAuditLog(t, auditLogPath) {
+				events = append(events, rec.Event)
+			}
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("audit events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
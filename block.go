@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BlockDevice describes one entry of /sys/class/block. Queue attributes of a
+// partition are taken from its parent disk.
+type BlockDevice struct {
+	Name              string   `json:"name"`
+	Type              string   `json:"type"` // disk, partition, dm, md or loop
+	MajorMinor        string   `json:"major_minor"`
+	Parent            string   `json:"parent,omitempty"`
+	SizeBytes         uint64   `json:"size_bytes"`
+	Rotational        bool     `json:"rotational"`
+	LogicalBlockSize  int      `json:"logical_block_size"`
+	PhysicalBlockSize int      `json:"physical_block_size"`
+	Scheduler         string   `json:"scheduler,omitempty"`
+	Model             string   `json:"model,omitempty"`
+	Serial            string   `json:"serial,omitempty"`
+	DMName            string   `json:"dm_name,omitempty"`
+	MDLevel           string   `json:"md_level,omitempty"`
+	Holders           []string `json:"holders,omitempty"`
+	Slaves            []string `json:"slaves,omitempty"`
+	Mountpoints       []string `json:"mountpoints,omitempty"`
+}
+
+// readDirNames returns the sorted entry names of dir, or nil if it can't be
+// read. Hidden entries are skipped; sysfs never has any.
+func readDirNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// activeScheduler returns the bracketed entry of queue/scheduler,
+// e.g. "mq-deadline" for "[mq-deadline] kyber bfq none".
+func activeScheduler(s string) string {
+	start := strings.IndexByte(s, '[')
+	end := strings.IndexByte(s, ']')
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start+1 : end]
+}
+
+// readBlockDevices walks <sysRoot>/class/block and attaches mountpoints by
+// matching each device's major:minor against mounts.
+func readBlockDevices(sysRoot string, mounts []MountInfo) ([]BlockDevice, error) {
+	base := filepath.Join(sysRoot, "class/block")
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+
+	mountsByDev := make(map[string][]string)
+	for _, m := range mounts {
+		mountsByDev[m.MajorMinor] = append(mountsByDev[m.MajorMinor], m.MountPoint)
+	}
+
+	devices := make([]BlockDevice, 0, len(entries))
+	for _, e := range entries {
+		dir, err := filepath.EvalSymlinks(filepath.Join(base, e.Name()))
+		if err != nil {
+			continue
+		}
+		dev := BlockDevice{
+			Name:       e.Name(),
+			MajorMinor: readSysfsString(filepath.Join(dir, "dev")),
+			Holders:    readDirNames(filepath.Join(dir, "holders")),
+			Slaves:     readDirNames(filepath.Join(dir, "slaves")),
+		}
+		if sectors, err := strconv.ParseUint(readSysfsString(filepath.Join(dir, "size")), 10, 64); err == nil {
+			dev.SizeBytes = sectors * 512 // sysfs size is always in 512-byte sectors
+		}
+
+		queueDir := dir
+		switch {
+		case fileExists(filepath.Join(dir, "partition")):
+			dev.Type = "partition"
+			queueDir = filepath.Dir(dir)
+			dev.Parent = filepath.Base(queueDir)
+		case fileExists(filepath.Join(dir, "dm")):
+			dev.Type = "dm"
+			dev.DMName = readSysfsString(filepath.Join(dir, "dm/name"))
+		case fileExists(filepath.Join(dir, "md")):
+			dev.Type = "md"
+			dev.MDLevel = readSysfsString(filepath.Join(dir, "md/level"))
+		case strings.HasPrefix(dev.Name, "loop"):
+			dev.Type = "loop"
+		default:
+			dev.Type = "disk"
+		}
+
+		dev.Rotational = readSysfsString(filepath.Join(queueDir, "queue/rotational")) == "1"
+		dev.LogicalBlockSize, _ = strconv.Atoi(readSysfsString(filepath.Join(queueDir, "queue/logical_block_size")))
+		dev.PhysicalBlockSize, _ = strconv.Atoi(readSysfsString(filepath.Join(queueDir, "queue/physical_block_size")))
+		dev.Scheduler = activeScheduler(readSysfsString(filepath.Join(queueDir, "queue/scheduler")))
+		dev.Model = readSysfsString(filepath.Join(queueDir, "device/model"))
+		dev.Serial = readSysfsString(filepath.Join(queueDir, "device/serial"))
+		dev.Mountpoints = mountsByDev[dev.MajorMinor]
+
+		if dev.Type == "loop" && dev.SizeBytes == 0 {
+			continue // unattached loop device
+		}
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices, nil
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.50 GiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// formatBlockDevice renders a device as one line of the machine info output.
+func formatBlockDevice(dev BlockDevice) string {
+	line := fmt.Sprintf("%s %s %s", dev.Name, dev.Type, formatBytes(dev.SizeBytes))
+	if dev.Parent != "" {
+		line += " parent=" + dev.Parent
+	}
+	if dev.DMName != "" {
+		line += " dm=" + dev.DMName
+	}
+	if dev.MDLevel != "" {
+		line += " level=" + dev.MDLevel
+	}
+	rota := 0
+	if dev.Rotational {
+		rota = 1
+	}
+	line += fmt.Sprintf(" rota=%d lbs=%d pbs=%d", rota, dev.LogicalBlockSize, dev.PhysicalBlockSize)
+	if dev.Scheduler != "" {
+		line += " sched=" + dev.Scheduler
+	}
+	if dev.Model != "" {
+		line += fmt.Sprintf(" model=%q", dev.Model)
+	}
+	if dev.Serial != "" {
+		line += " serial=" + dev.Serial
+	}
+	if len(dev.Slaves) > 0 {
+		line += " slaves=" + strings.Join(dev.Slaves, ",")
+	}
+	if len(dev.Holders) > 0 {
+		line += " holders=" + strings.Join(dev.Holders, ",")
+	}
+	if len(dev.Mountpoints) > 0 {
+		line += " mounts=" + strings.Join(dev.Mountpoints, ",")
+	}
+	return line
+}
+
+// readBlockDevicesFrom lists block devices under sysRoot with their mountpoints.
+func readBlockDevicesFrom(sysRoot string) string {
+	mounts, err := readMountInfo()
+	if err != nil {
+		fmt.Println("Reading /proc/self/mountinfo failed:", err)
+	}
+	devices, err := readBlockDevices(sysRoot, mounts)
+	if err != nil {
+		return "Reading block devices from sysfs failed: " + err.Error()
+	}
+	lines := make([]string, 0, len(devices))
+	for _, dev := range devices {
+		lines = append(lines, formatBlockDevice(dev))
+	}
+	return strings.Join(lines, "\n")
+}
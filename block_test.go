@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================== readBlockDevices (fake sysfs in testdata) =====================
+func TestReadBlockDevices(t *testing.T) {
+	data, err := os.ReadFile("testdata/proc/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices, err := readBlockDevices("testdata/sys", parseMountInfo(data))
+	if err != nil {
+		t.Fatalf("readBlockDevices() unexpected error: %v", err)
+	}
+
+	byName := make(map[string]BlockDevice)
+	for _, d := range devices {
+		byName[d.Name] = d
+	}
+
+	tests := []struct {
+		name    string
+		dev     string
+		want    BlockDevice
+		success bool
+	}{
+		{
+			name: "success: rotational disk",
+			dev:  "sda",
+			want: BlockDevice{
+				Name: "sda", Type: "disk", MajorMinor: "8:0", SizeBytes: 1953525168 * 512, Rotational: true,
+				LogicalBlockSize: 512, PhysicalBlockSize: 4096, Scheduler: "mq-deadline",
+				Model: "ST1000DM010-2EP1", Serial: "Z9A1B2C3",
+			},
+			success: true,
+		},
+		{
+			name: "success: partition inherits queue from parent and is mounted",
+			dev:  "sda1",
+			want: BlockDevice{
+				Name: "sda1", Type: "partition", MajorMinor: "8:1", Parent: "sda", SizeBytes: 1048576 * 512, Rotational: true,
+				LogicalBlockSize: 512, PhysicalBlockSize: 4096, Scheduler: "mq-deadline",
+				Model: "ST1000DM010-2EP1", Serial: "Z9A1B2C3", Mountpoints: []string{"/"},
+			},
+			success: true,
+		},
+		{
+			name: "success: LVM physical volume partition held by dm",
+			dev:  "sda2",
+			want: BlockDevice{
+				Name: "sda2", Type: "partition", MajorMinor: "8:2", Parent: "sda", SizeBytes: 1952474591 * 512, Rotational: true,
+				LogicalBlockSize: 512, PhysicalBlockSize: 4096, Scheduler: "mq-deadline",
+				Model: "ST1000DM010-2EP1", Serial: "Z9A1B2C3", Holders: []string{"dm-0"},
+			},
+			success: true,
+		},
+		{
+			name: "success: device-mapper volume",
+			dev:  "dm-0",
+			want: BlockDevice{
+				Name: "dm-0", Type: "dm", MajorMinor: "253:0", SizeBytes: 204800 * 512,
+				LogicalBlockSize: 512, PhysicalBlockSize: 4096, Scheduler: "none", DMName: "testvg-testlv1",
+				Slaves: []string{"sda2"}, Mountpoints: []string{"/mnt/lvm1"},
+			},
+			success: true,
+		},
+		{
+			name: "success: md raid with two members and two mounts",
+			dev:  "md0",
+			want: BlockDevice{
+				Name: "md0", Type: "md", MajorMinor: "9:0", SizeBytes: 1000083456 * 512,
+				LogicalBlockSize: 512, PhysicalBlockSize: 512, Scheduler: "none", MDLevel: "raid1",
+				Slaves: []string{"nvme0n1", "nvme1n1"}, Mountpoints: []string{"/srv/my data", "/var/lib/exports"},
+			},
+			success: true,
+		},
+		{
+			name: "success: attached loop device",
+			dev:  "loop1",
+			want: BlockDevice{
+				Name: "loop1", Type: "loop", MajorMinor: "7:1", SizeBytes: 204800 * 512,
+				LogicalBlockSize: 512, PhysicalBlockSize: 512, Scheduler: "none",
+			},
+			success: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := byName[tt.dev]
+			if !ok {
+				t.Fatalf("device %s not found", tt.dev)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("device %s = %+v, want %+v", tt.dev, got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := byName["loop0"]; ok {
+		t.Error("unattached loop0 should be skipped")
+	}
+	if got := byName["nvme0n1"].Holders; !reflect.DeepEqual(got, []string{"md0"}) {
+		t.Errorf("nvme0n1 holders = %v, want [md0]", got)
+	}
+}
+
+func TestReadBlockDevices_MissingSysfs(t *testing.T) {
+	if _, err := readBlockDevices("testdata/does-not-exist", nil); err == nil {
+		t.Error("readBlockDevices() expected error for missing sysfs, got nil")
+	}
+}
+
+// ===================== activeScheduler / formatBytes =====================
+func TestActiveScheduler(t *testing.T) {
+	tests := map[string]string{
+		"[mq-deadline] kyber bfq none": "mq-deadline",
+		"mq-deadline [none]":           "none",
+		"none":                         "none",
+		"":                             "",
+	}
+	for in, want := range tests {
+		if got := activeScheduler(in); got != want {
+			t.Errorf("activeScheduler(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		0:                  "0 B",
+		1023:               "1023 B",
+		1024:               "1.00 KiB",
+		100 * 1024 * 1024:  "100.00 MiB",
+		1536 * 1024 * 1024: "1.50 GiB",
+		1953525168 * 512:   "931.51 GiB",
+	}
+	for in, want := range tests {
+		if got := formatBytes(in); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// ===================== readBlockDevicesFrom =====================
+func TestReadBlockDevicesFrom(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	tests := []struct {
+		name        string
+		sysRoot     string
+		mountErr    error
+		wantContain []string
+		success     bool
+	}{
+		{
+			name:    "success: fake sysfs tree",
+			sysRoot: "testdata/sys",
+			wantContain: []string{
+				`sda disk 931.51 GiB rota=1 lbs=512 pbs=4096 sched=mq-deadline model="ST1000DM010-2EP1" serial=Z9A1B2C3`,
+				"dm-0 dm 100.00 MiB dm=testvg-testlv1 rota=0 lbs=512 pbs=4096 sched=none slaves=sda2 mounts=/mnt/lvm1",
+				"sda2 partition",
+				"holders=dm-0",
+			},
+			success: true,
+		},
+		{
+			name:        "success: mountinfo unreadable still lists devices",
+			sysRoot:     "testdata/sys",
+			mountErr:    errors.New("permission denied"),
+			wantContain: []string{"md0 md"},
+			success:     true,
+		},
+		{
+			name:        "failure: sysfs missing",
+			sysRoot:     "testdata/does-not-exist",
+			wantContain: []string{"Reading block devices from sysfs failed"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if path == "/proc/self/mountinfo" {
+					if tt.mountErr != nil {
+						return nil, tt.mountErr
+					}
+					return os.ReadFile("testdata/proc/mountinfo")
+				}
+				return os.ReadFile(path)
+			}
+			got := readBlockDevicesFrom(tt.sysRoot)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("readBlockDevicesFrom() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
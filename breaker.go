@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error classes. A permanent error won't go away by retrying soon (missing
+// binary, kernel module or capability, denied by policy); anything else,
+// including a skip for too little free space, is assumed transient.
+const (
+	Transient = "transient"
+	Permanent = "permanent"
+)
+
+// classifyError decides whether retrying a failed procedure can help.
+func classifyError(err error) string {
+	var skip *SkipError
+	var denied *PolicyError
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &skip):
+		if skip.Transient {
+			return Transient
+		}
+		return Permanent
+	case errors.As(err, &denied):
+		return Permanent
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		return Permanent // the binary is missing or not executable
+	case errors.As(err, &exitErr) && (exitErr.ExitCode() == 126 || exitErr.ExitCode() == 127):
+		return Permanent // a shell step couldn't find or execute its command
+	}
+	return Transient
+}
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// BreakerConfig tunes a Breaker. After a transient failure the next attempt
+// waits BaseDelay, doubling per consecutive failure up to MaxDelay. Threshold
+// consecutive failures, or one permanent failure, open the circuit for
+// Cooldown; then a single half-open probe decides whether it closes again.
+type BreakerConfig struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Cooldown  time.Duration
+}
+
+// BreakerStatus is a snapshot of a Breaker for status output.
+type BreakerStatus struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastErrorClass      string    `json:"last_error_class,omitempty"`
+	RetryAt             time.Time `json:"retry_at,omitempty"`
+	Trips               int       `json:"trips"`
+}
+
+// Breaker tracks the failures of one procedure.
+type Breaker struct {
+	cfg    BreakerConfig
+	mu     sync.Mutex
+	status BreakerStatus
+}
+
+func newBreaker(cfg BreakerConfig) *Breaker {
+	return &Breaker{cfg: cfg, status: BreakerStatus{State: BreakerClosed}}
+}
+
+// Allow reports whether the procedure may run at now, and why not.
+func (b *Breaker) Allow(now time.Time) (bool, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := &b.status
+	if !now.Before(st.RetryAt) {
+		if st.State == BreakerOpen {
+			st.State = BreakerHalfOpen
+		}
+		return true, ""
+	}
+	if st.State == BreakerOpen {
+		return false, fmt.Sprintf("circuit open until %s after %d consecutive failure(s); last %s error: %s",
+			st.RetryAt.Format(time.TimeOnly), st.ConsecutiveFailures, st.LastErrorClass, st.LastError)
+	}
+	return false, fmt.Sprintf("backing off until %s after %d consecutive failure(s)",
+		st.RetryAt.Format(time.TimeOnly), st.ConsecutiveFailures)
+}
+
+// Record updates the breaker with the outcome of a run that ended at now.
+func (b *Breaker) Record(now time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := &b.status
+	if err == nil {
+		*st = BreakerStatus{State: BreakerClosed, Trips: st.Trips}
+		return
+	}
+	st.ConsecutiveFailures++
+	st.LastError, _, _ = strings.Cut(err.Error(), "\n")
+	st.LastErrorClass = classifyError(err)
+	if st.State == BreakerHalfOpen || st.LastErrorClass == Permanent || st.ConsecutiveFailures >= b.cfg.Threshold {
+		st.State = BreakerOpen
+		st.RetryAt = now.Add(b.cfg.Cooldown)
+		st.Trips++
+		return
+	}
+	delay := b.cfg.BaseDelay << (st.ConsecutiveFailures - 1)
+	if delay > b.cfg.MaxDelay || delay <= 0 {
+		delay = b.cfg.MaxDelay
+	}
+	st.RetryAt = now.Add(delay)
+}
+
+// Status returns a snapshot of the breaker.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status
+}
+
+// formatBreakerStatus renders the status line printed after each attempt.
+func formatBreakerStatus(procedure string, st BreakerStatus) string {
+	line := fmt.Sprintf("Circuit breaker (%s): %s, %d consecutive failure(s), tripped %d time(s)",
+		procedure, st.State, st.ConsecutiveFailures, st.Trips)
+	if st.ConsecutiveFailures > 0 {
+		line += fmt.Sprintf(", next attempt at %s (last error %s: %s)", st.RetryAt.Format(time.TimeOnly), st.LastErrorClass, st.LastError)
+	}
+	return line
+}
+
+// Validate reports a config the breaker can't work with.
+func (c BreakerConfig) Validate() error {
+	switch {
+	case c.Threshold < 1:
+		return fmt.Errorf("threshold must be at least 1, got %d", c.Threshold)
+	case c.BaseDelay <= 0 || c.MaxDelay <= 0 || c.Cooldown <= 0:
+		return fmt.Errorf("backoff delays and cooldown must be positive")
+	case c.BaseDelay > c.MaxDelay:
+		return fmt.Errorf("base backoff %s exceeds max backoff %s", c.BaseDelay, c.MaxDelay)
+	}
+	return nil
+}
+
+// breakerConfig is set from flags by main; breakers holds one Breaker per
+// procedure name, created on first use.
+var (
+	breakerConfig = BreakerConfig{Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: 10 * time.Minute, Cooldown: 30 * time.Minute}
+	breakersMu    sync.Mutex
+	breakers      = map[string]*Breaker{}
+)
+
+func procedureBreaker(name string) *Breaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	b, ok := breakers[name]
+	if !ok {
+		b = newBreaker(breakerConfig)
+		breakers[name] = b
+	}
+	return b
+}
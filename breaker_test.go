@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================== classifyError =====================
+func TestClassifyError(t *testing.T) {
+	exitErr := func(code int) error {
+		return exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	}
+	tests := []struct {
+		name    string
+		err     error
+		want    string
+		success bool
+	}{
+		{
+			name:    "success: missing kernel module is permanent",
+			err:     &SkipError{Reason: "kernel module dm_mod is not available"},
+			want:    Permanent,
+			success: true,
+		},
+		{
+			name:    "success: too little free space is transient",
+			err:     &SkipError{Reason: "not enough free space on /root: 50.00 MiB available, 100.00 MiB needed", Transient: true},
+			want:    Transient,
+			success: true,
+		},
+		{
+			name:    "success: policy denial is permanent",
+			err:     &PolicyError{Argv: []string{"dd"}, Reason: "binary dd is not allowlisted"},
+			want:    Permanent,
+			success: true,
+		},
+		{
+			name:    "success: missing binary is permanent",
+			err:     &CommandError{Argv: []string{"pvcreate"}, Err: exec.Command("no-such-binary-for-breaker-test").Run()},
+			want:    Permanent,
+			success: true,
+		},
+		{
+			name:    "success: shell command not found is permanent",
+			err:     &CommandError{Argv: []string{"bash", "-c", "pvcreate"}, Err: exitErr(127)},
+			want:    Permanent,
+			success: true,
+		},
+		{
+			name:    "success: failing command is transient",
+			err:     &CommandError{Argv: []string{"umount", "/mnt/disk1"}, Output: "target is busy", Err: exitErr(32)},
+			want:    Transient,
+			success: true,
+		},
+		{
+			name:    "success: wrapped unknown error is transient",
+			err:     fmt.Errorf("no free loop device: %w", errors.New("resource busy")),
+			want:    Transient,
+			success: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== Breaker =====================
+func TestBreaker(t *testing.T) {
+	cfg := BreakerConfig{Threshold: 3, BaseDelay: 10 * time.Second, MaxDelay: 15 * time.Second, Cooldown: time.Minute}
+	transient := errors.New("device busy\nmore output")
+	permanent := &SkipError{Reason: "missing dm_mod"}
+	type event struct {
+		at        time.Duration // relative to start
+		err       error         // outcome if the run is allowed
+		wantAllow bool
+		wantState string
+	}
+	tests := []struct {
+		name      string
+		events    []event
+		wantTrips int
+		success   bool
+	}{
+		{
+			name: "success: transient failures back off exponentially up to the cap, then trip",
+			events: []event{
+				{at: 0, err: transient, wantAllow: true, wantState: BreakerClosed},
+				{at: 5 * time.Second, wantAllow: false, wantState: BreakerClosed},
+				{at: 10 * time.Second, err: transient, wantAllow: true, wantState: BreakerClosed},
+				{at: 24 * time.Second, wantAllow: false, wantState: BreakerClosed}, // 20s is capped at 15s
+				{at: 25 * time.Second, err: transient, wantAllow: true, wantState: BreakerOpen},
+				{at: time.Minute, wantAllow: false, wantState: BreakerOpen},
+			},
+			wantTrips: 1,
+			success:   true,
+		},
+		{
+			name: "success: permanent failure trips immediately and a good probe closes",
+			events: []event{
+				{at: 0, err: permanent, wantAllow: true, wantState: BreakerOpen},
+				{at: 59 * time.Second, wantAllow: false, wantState: BreakerOpen},
+				{at: time.Minute, err: nil, wantAllow: true, wantState: BreakerClosed},
+				{at: time.Minute + time.Second, err: nil, wantAllow: true, wantState: BreakerClosed},
+			},
+			wantTrips: 1,
+			success:   true,
+		},
+		{
+			name: "failure: a failed half-open probe reopens the circuit",
+			events: []event{
+				{at: 0, err: permanent, wantAllow: true, wantState: BreakerOpen},
+				{at: time.Minute, err: transient, wantAllow: true, wantState: BreakerOpen},
+				{at: 90 * time.Second, wantAllow: false, wantState: BreakerOpen},
+			},
+			wantTrips: 2,
+			success:   false,
+		},
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBreaker(cfg)
+			for i, ev := range tt.events {
+				now := start.Add(ev.at)
+				allowed, reason := b.Allow(now)
+				if allowed != ev.wantAllow {
+					t.Fatalf("event %d: Allow(+%s) = %v (%s), want %v", i, ev.at, allowed, reason, ev.wantAllow)
+				}
+				if allowed {
+					b.Record(now, ev.err)
+				} else if reason == "" {
+					t.Errorf("event %d: deferred without a reason", i)
+				}
+				if got := b.Status().State; got != ev.wantState {
+					t.Errorf("event %d: state = %s, want %s", i, got, ev.wantState)
+				}
+			}
+			if got := b.Status().Trips; got != tt.wantTrips {
+				t.Errorf("trips = %d, want %d", got, tt.wantTrips)
+			}
+		})
+	}
+}
+
+func TestBreaker_StatusReportsLastError(t *testing.T) {
+	b := newBreaker(BreakerConfig{Threshold: 5, BaseDelay: time.Second, MaxDelay: time.Minute, Cooldown: time.Hour})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b.Record(now, &SkipError{Reason: "kernel module dm_mod is not available"})
+
+	line := formatBreakerStatus("LVM", b.Status())
+	want := "Circuit breaker (LVM): open, 1 consecutive failure(s), tripped 1 time(s), next attempt at 13:00:00 (last error permanent: skipped: kernel module dm_mod is not available)"
+	if line != want {
+		t.Errorf("formatBreakerStatus() = %q, want %q", line, want)
+	}
+	if _, reason := b.Allow(now.Add(time.Minute)); !strings.Contains(reason, "circuit open until 13:00:00") {
+		t.Errorf("Allow() reason = %q", reason)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultCapLastCap is CAP_CHECKPOINT_RESTORE (Linux 5.9), used when
+// /proc/sys/kernel/cap_last_cap can't be read.
+const defaultCapLastCap = 40
+
+// Capability numbers the procedures depend on (see capabilities(7)).
+const (
+	capMknod    = 27
+	capSysAdmin = 21
+)
+
+// capNames are the capability names indexed by bit number.
+var capNames = []string{
+	"CAP_CHOWN", "CAP_DAC_OVERRIDE", "CAP_DAC_READ_SEARCH", "CAP_FOWNER", "CAP_FSETID",
+	"CAP_KILL", "CAP_SETGID", "CAP_SETUID", "CAP_SETPCAP", "CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE", "CAP_NET_BROADCAST", "CAP_NET_ADMIN", "CAP_NET_RAW", "CAP_IPC_LOCK",
+	"CAP_IPC_OWNER", "CAP_SYS_MODULE", "CAP_SYS_RAWIO", "CAP_SYS_CHROOT", "CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT", "CAP_SYS_ADMIN", "CAP_SYS_BOOT", "CAP_SYS_NICE", "CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME", "CAP_SYS_TTY_CONFIG", "CAP_MKNOD", "CAP_LEASE", "CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL", "CAP_SETFCAP", "CAP_MAC_OVERRIDE", "CAP_MAC_ADMIN", "CAP_SYSLOG",
+	"CAP_WAKE_ALARM", "CAP_BLOCK_SUSPEND", "CAP_AUDIT_READ", "CAP_PERFMON", "CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// capName returns the name of a capability bit, or "cap_N" for bits newer
+// than capNames.
+func capName(bit int) string {
+	if bit < len(capNames) {
+		return capNames[bit]
+	}
+	return fmt.Sprintf("cap_%d", bit)
+}
+
+// decodeCaps lists the names of the capabilities set in mask.
+func decodeCaps(mask uint64) []string {
+	var names []string
+	for bit := 0; bit < 64; bit++ {
+		if mask&(1<<bit) != 0 {
+			names = append(names, capName(bit))
+		}
+	}
+	return names
+}
+
+// parseCapMask returns a capability mask such as "CapEff" or "CapBnd" from
+// /proc/<pid>/status.
+func parseCapMask(data []byte, field string) (uint64, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if val, ok := strings.CutPrefix(line, field+":"); ok {
+			return strconv.ParseUint(strings.TrimSpace(val), 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("no %s line", field)
+}
+
+// allCaps returns the mask with every capability up to lastCap set.
+func allCaps(lastCap int) uint64 {
+	if lastCap >= 63 {
+		return ^uint64(0)
+	}
+	return uint64(1)<<(lastCap+1) - 1
+}
+
+func readCapLastCap() int {
+	if n, err := strconv.Atoi(readSysfsString("/proc/sys/kernel/cap_last_cap")); err == nil {
+		return n
+	}
+	return defaultCapLastCap
+}
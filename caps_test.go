@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// ===================== parseCapMask =====================
+func TestParseCapMask(t *testing.T) {
+	data, err := os.ReadFile("testdata/proc/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parseCapMask(data, "CapEff"); err != nil || got != 0xa80425fb {
+		t.Errorf("parseCapMask(CapEff) = %x, %v, want a80425fb", got, err)
+	}
+	if got, err := parseCapMask(data, "CapInh"); err != nil || got != 0 {
+		t.Errorf("parseCapMask(CapInh) = %x, %v, want 0", got, err)
+	}
+	if _, err := parseCapMask([]byte("Name:\tapp\n"), "CapEff"); err == nil {
+		t.Error("parseCapMask() without CapEff: error = nil, want error")
+	}
+}
+
+// ===================== decodeCaps =====================
+func TestDecodeCaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		mask    uint64
+		want    string
+		success bool
+	}{
+		{name: "success: none", mask: 0, want: "", success: true},
+		{name: "success: sys_admin and mknod", mask: 1<<capSysAdmin | 1<<capMknod, want: "CAP_SYS_ADMIN,CAP_MKNOD", success: true},
+		{
+			name:    "success: docker default set",
+			mask:    0xa80425fb,
+			want:    "CAP_CHOWN,CAP_DAC_OVERRIDE,CAP_FOWNER,CAP_FSETID,CAP_KILL,CAP_SETGID,CAP_SETUID,CAP_SETPCAP,CAP_NET_BIND_SERVICE,CAP_NET_RAW,CAP_SYS_CHROOT,CAP_MKNOD,CAP_AUDIT_WRITE,CAP_SETFCAP",
+			success: true,
+		},
+		{name: "success: bit newer than the table", mask: 1 << 45, want: "cap_45", success: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(decodeCaps(tt.mask), ","); got != tt.want {
+				t.Errorf("decodeCaps(%x) = %q, want %q", tt.mask, got, tt.want)
+			}
+		})
+	}
+}
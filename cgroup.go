@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroupV1Unlimited is the threshold above which a cgroup v1 memory limit
+// means "no limit" (the kernel reports PAGE_COUNTER_MAX rounded to pages).
+const cgroupV1Unlimited = int64(1) << 60
+
+// CgroupLimits holds the resource limits and usage of our own cgroup. Limits
+// are -1 when unlimited; usage values are -1 when the kernel doesn't expose them.
+type CgroupLimits struct {
+	Version          int      `json:"version"`
+	Path             string   `json:"path"`
+	Dir              string   `json:"dir"` // resolved directory (memory controller for v1)
+	MemoryLimitBytes int64    `json:"memory_limit_bytes"`
+	MemoryUsageBytes int64    `json:"memory_usage_bytes"`
+	MemoryPeakBytes  int64    `json:"memory_peak_bytes"`
+	CPUQuotaUsec     int64    `json:"cpu_quota_usec"`
+	CPUPeriodUsec    int64    `json:"cpu_period_usec"`
+	PidsLimit        int64    `json:"pids_limit"`
+	PidsCurrent      int64    `json:"pids_current"`
+	IOMax            []string `json:"io_max,omitempty"`
+	NrPeriods        int64    `json:"nr_periods"`
+	NrThrottled      int64    `json:"nr_throttled"`
+	ThrottledUsec    int64    `json:"throttled_usec"`
+}
+
+// EffectiveCores converts the CFS quota into a number of CPUs, or 0 when unlimited.
+func (c CgroupLimits) EffectiveCores() float64 {
+	if c.CPUQuotaUsec <= 0 || c.CPUPeriodUsec <= 0 {
+		return 0
+	}
+	return float64(c.CPUQuotaUsec) / float64(c.CPUPeriodUsec)
+}
+
+// parseProcCgroup maps each controller of /proc/self/cgroup to its path. The
+// unified (v2) hierarchy is returned under the key "".
+func parseProcCgroup(data []byte) map[string]string {
+	paths := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[1] == "" {
+			paths[""] = parts[2]
+			continue
+		}
+		for _, ctrl := range strings.Split(parts[1], ",") {
+			paths[strings.TrimPrefix(ctrl, "name=")] = parts[2]
+		}
+		paths[parts[1]] = parts[2]
+	}
+	return paths
+}
+
+// readCgroupInt reads a single-value cgroup file. "max" and missing files
+// yield -1; so do v1 values at or above cgroupV1Unlimited.
+func readCgroupInt(path string) int64 {
+	s := readSysfsString(path)
+	if s == "" || s == "max" {
+		return -1
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n >= cgroupV1Unlimited {
+		return -1
+	}
+	return n
+}
+
+// readCgroupKeyed reads a flat-keyed file such as cpu.stat into a map.
+func readCgroupKeyed(path string) map[string]int64 {
+	values := make(map[string]int64)
+	for _, line := range strings.Split(readSysfsString(path), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			values[fields[0]] = n
+		}
+	}
+	return values
+}
+
+// readCgroupLines returns the non-empty lines of a cgroup file.
+func readCgroupLines(path string) []string {
+	var lines []string
+	for _, line := range strings.Split(readSysfsString(path), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// cgroupDir locates our cgroup below a hierarchy mount. Inside a cgroup
+// namespace /proc/self/cgroup reports "/" relative to the namespace root, and
+// so does the mount; if the full path is missing, fall back to the mount itself.
+func cgroupDir(mount, path string) string {
+	dir := filepath.Join(mount, path)
+	if fileExists(dir) {
+		return dir
+	}
+	return mount
+}
+
+// readCgroupLimits detects the cgroup version from /proc/self/cgroup and
+// reads our limits from the hierarchy mounted at cgroupRoot (/sys/fs/cgroup).
+func readCgroupLimits(cgroupRoot string) (CgroupLimits, error) {
+	data, err := ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return CgroupLimits{}, err
+	}
+	paths := parseProcCgroup(data)
+
+	if _, ok := paths["memory"]; !ok {
+		if path, ok := paths[""]; ok {
+			return readCgroupV2(cgroupRoot, path), nil
+		}
+		return CgroupLimits{}, fmt.Errorf("no cgroup memory controller or unified hierarchy in /proc/self/cgroup")
+	}
+	return readCgroupV1(cgroupRoot, paths), nil
+}
+
+func readCgroupV2(cgroupRoot, path string) CgroupLimits {
+	dir := cgroupDir(cgroupRoot, path)
+	c := CgroupLimits{
+		Version:          2,
+		Path:             path,
+		Dir:              dir,
+		MemoryLimitBytes: readCgroupInt(filepath.Join(dir, "memory.max")),
+		MemoryUsageBytes: readCgroupInt(filepath.Join(dir, "memory.current")),
+		MemoryPeakBytes:  readCgroupInt(filepath.Join(dir, "memory.peak")),
+		CPUQuotaUsec:     -1,
+		PidsLimit:        readCgroupInt(filepath.Join(dir, "pids.max")),
+		PidsCurrent:      readCgroupInt(filepath.Join(dir, "pids.current")),
+		IOMax:            readCgroupLines(filepath.Join(dir, "io.max")),
+	}
+
+	// cpu.max is "$MAX $PERIOD" where $MAX may be "max".
+	if fields := strings.Fields(readSysfsString(filepath.Join(dir, "cpu.max"))); len(fields) == 2 {
+		if quota, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+			c.CPUQuotaUsec = quota
+		}
+		c.CPUPeriodUsec, _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+
+	stat := readCgroupKeyed(filepath.Join(dir, "cpu.stat"))
+	c.NrPeriods = stat["nr_periods"]
+	c.NrThrottled = stat["nr_throttled"]
+	c.ThrottledUsec = stat["throttled_usec"]
+	return c
+}
+
+// v1ControllerDir finds the mount of a v1 controller. Co-mounted controllers
+// live under e.g. "cpu,cpuacct", usually with a per-name symlink next to it.
+func v1ControllerDir(cgroupRoot string, paths map[string]string, ctrl string) string {
+	candidates := []string{ctrl}
+	for key := range paths {
+		if slices.Contains(strings.Split(key, ","), ctrl) && key != ctrl {
+			candidates = append(candidates, key)
+		}
+	}
+	for _, name := range candidates {
+		if mount := filepath.Join(cgroupRoot, name); fileExists(mount) {
+			return cgroupDir(mount, paths[ctrl])
+		}
+	}
+	return filepath.Join(cgroupRoot, ctrl)
+}
+
+func readCgroupV1(cgroupRoot string, paths map[string]string) CgroupLimits {
+	mem := v1ControllerDir(cgroupRoot, paths, "memory")
+	cpu := v1ControllerDir(cgroupRoot, paths, "cpu")
+	pids := v1ControllerDir(cgroupRoot, paths, "pids")
+	blkio := v1ControllerDir(cgroupRoot, paths, "blkio")
+
+	c := CgroupLimits{
+		Version:          1,
+		Path:             paths["memory"],
+		Dir:              mem,
+		MemoryLimitBytes: readCgroupInt(filepath.Join(mem, "memory.limit_in_bytes")),
+		MemoryUsageBytes: readCgroupInt(filepath.Join(mem, "memory.usage_in_bytes")),
+		MemoryPeakBytes:  readCgroupInt(filepath.Join(mem, "memory.max_usage_in_bytes")),
+		CPUQuotaUsec:     readCgroupInt(filepath.Join(cpu, "cpu.cfs_quota_us")),
+		CPUPeriodUsec:    readCgroupInt(filepath.Join(cpu, "cpu.cfs_period_us")),
+		PidsLimit:        readCgroupInt(filepath.Join(pids, "pids.max")),
+		PidsCurrent:      readCgroupInt(filepath.Join(pids, "pids.current")),
+	}
+	for _, name := range []string{"read_bps_device", "write_bps_device", "read_iops_device", "write_iops_device"} {
+		for _, line := range readCgroupLines(filepath.Join(blkio, "blkio.throttle."+name)) {
+			c.IOMax = append(c.IOMax, name+" "+line)
+		}
+	}
+
+	stat := readCgroupKeyed(filepath.Join(cpu, "cpu.stat"))
+	c.NrPeriods = stat["nr_periods"]
+	c.NrThrottled = stat["nr_throttled"]
+	c.ThrottledUsec = stat["throttled_time"] / 1000 // v1 reports nanoseconds
+	return c
+}
+
+func formatCgroupBytes(n int64, unlimited string) string {
+	if n < 0 {
+		return unlimited
+	}
+	return formatBytes(uint64(n))
+}
+
+// formatCgroupLimits renders the container limits for the machine info
+// output; hostMemKB and hostCPUs are the host-wide values shown beside them.
+func formatCgroupLimits(c CgroupLimits, hostMemKB float64, hostCPUs int) string {
+	memLimit := formatCgroupBytes(c.MemoryLimitBytes, "unlimited")
+	if c.MemoryLimitBytes >= 0 && hostMemKB > 0 {
+		memLimit += fmt.Sprintf(" (%.0f%% of host)", float64(c.MemoryLimitBytes)/(hostMemKB*1024)*100)
+	}
+	cores := "unlimited"
+	if eff := c.EffectiveCores(); eff > 0 {
+		cores = fmt.Sprintf("%.2f cores (%d/%dus)", eff, c.CPUQuotaUsec, c.CPUPeriodUsec)
+	}
+	pidsCurrent, pidsLimit := "n/a", "unlimited"
+	if c.PidsCurrent >= 0 {
+		pidsCurrent = strconv.FormatInt(c.PidsCurrent, 10)
+	}
+	if c.PidsLimit >= 0 {
+		pidsLimit = strconv.FormatInt(c.PidsLimit, 10)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Cgroup: v%d %s", c.Version, c.Path),
+		fmt.Sprintf("Container memory: usage %s, peak %s, limit %s",
+			formatCgroupBytes(c.MemoryUsageBytes, "n/a"), formatCgroupBytes(c.MemoryPeakBytes, "n/a"), memLimit),
+		fmt.Sprintf("Container CPU: quota %s, host %d CPUs; throttled %d of %d periods (%s)",
+			cores, hostCPUs, c.NrThrottled, c.NrPeriods, time.Duration(c.ThrottledUsec)*time.Microsecond),
+		fmt.Sprintf("Container pids: %s of %s", pidsCurrent, pidsLimit),
+		fmt.Sprintf("Container io.max: %s", orNone(strings.Join(c.IOMax, "; "))),
+	}
+	return strings.Join(lines, "\n")
+}
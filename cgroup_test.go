@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================== parseProcCgroup =====================
+func TestParseProcCgroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		success bool
+	}{
+		{
+			name:    "success: cgroup v2",
+			input:   "0::/kubepods/pod123/ctr\n",
+			want:    map[string]string{"": "/kubepods/pod123/ctr"},
+			success: true,
+		},
+		{
+			name:  "success: cgroup v1 with co-mounted controllers",
+			input: "4:memory:/docker/abc\n3:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n0::/\n",
+			want: map[string]string{
+				"memory": "/docker/abc", "cpu": "/docker/abc", "cpuacct": "/docker/abc",
+				"cpu,cpuacct": "/docker/abc", "systemd": "/docker/abc", "name=systemd": "/docker/abc", "": "/",
+			},
+			success: true,
+		},
+		{
+			name:    "failure: malformed lines skipped",
+			input:   "garbage\n\n",
+			want:    map[string]string{},
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseProcCgroup([]byte(tt.input)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProcCgroup() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== readCgroupLimits (fake cgroupfs in testdata) =====================
+func TestReadCgroupLimits(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	tests := []struct {
+		name       string
+		root       string
+		procCgroup string
+		readErr    error
+		want       CgroupLimits
+		wantErr    bool
+		success    bool
+	}{
+		{
+			name:       "success: v2 with full cgroup path",
+			root:       "testdata/cgroup/v2",
+			procCgroup: "0::/kubepods/pod123/ctr\n",
+			want: CgroupLimits{
+				Version: 2, Path: "/kubepods/pod123/ctr", Dir: "testdata/cgroup/v2/kubepods/pod123/ctr",
+				MemoryLimitBytes: 536870912, MemoryUsageBytes: 125829120, MemoryPeakBytes: 209715200,
+				CPUQuotaUsec: 50000, CPUPeriodUsec: 100000, PidsLimit: 1024, PidsCurrent: 12,
+				IOMax:     []string{"8:0 rbps=1048576 wbps=max riops=max wiops=max"},
+				NrPeriods: 340, NrThrottled: 12, ThrottledUsec: 1200000,
+			},
+			success: true,
+		},
+		{
+			name:       "success: v2 inside cgroup namespace falls back to mount",
+			root:       "testdata/cgroup/v2/kubepods/pod123/ctr",
+			procCgroup: "0::/\n",
+			want: CgroupLimits{
+				Version: 2, Path: "/", Dir: "testdata/cgroup/v2/kubepods/pod123/ctr",
+				MemoryLimitBytes: 536870912, MemoryUsageBytes: 125829120, MemoryPeakBytes: 209715200,
+				CPUQuotaUsec: 50000, CPUPeriodUsec: 100000, PidsLimit: 1024, PidsCurrent: 12,
+				IOMax:     []string{"8:0 rbps=1048576 wbps=max riops=max wiops=max"},
+				NrPeriods: 340, NrThrottled: 12, ThrottledUsec: 1200000,
+			},
+			success: true,
+		},
+		{
+			name:       "success: v1 unlimited memory and co-mounted cpu,cpuacct",
+			root:       "testdata/cgroup/v1",
+			procCgroup: "6:pids:/docker/abc\n5:blkio:/docker/abc\n4:memory:/docker/abc\n3:cpu,cpuacct:/docker/abc\n",
+			want: CgroupLimits{
+				Version: 1, Path: "/docker/abc", Dir: "testdata/cgroup/v1/memory",
+				MemoryLimitBytes: -1, MemoryUsageBytes: 104857600, MemoryPeakBytes: 157286400,
+				CPUQuotaUsec: 200000, CPUPeriodUsec: 100000, PidsLimit: -1, PidsCurrent: 7,
+				IOMax:     []string{"read_bps_device 8:0 10485760"},
+				NrPeriods: 500, NrThrottled: 20, ThrottledUsec: 1500000,
+			},
+			success: true,
+		},
+		{
+			name:       "success: v2 with no limits files present",
+			root:       "testdata/does-not-exist",
+			procCgroup: "0::/\n",
+			want: CgroupLimits{
+				Version: 2, Path: "/", Dir: "testdata/does-not-exist", MemoryLimitBytes: -1, MemoryUsageBytes: -1, MemoryPeakBytes: -1,
+				CPUQuotaUsec: -1, PidsLimit: -1, PidsCurrent: -1,
+			},
+			success: true,
+		},
+		{
+			name:    "failure: /proc/self/cgroup unreadable",
+			root:    "testdata/cgroup/v2",
+			readErr: errors.New("permission denied"),
+			wantErr: true,
+			success: false,
+		},
+		{
+			name:       "failure: no usable hierarchy",
+			root:       "testdata/cgroup/v2",
+			procCgroup: "1:name=systemd:/\n",
+			wantErr:    true,
+			success:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if path == "/proc/self/cgroup" {
+					return []byte(tt.procCgroup), tt.readErr
+				}
+				return os.ReadFile(path)
+			}
+			got, err := readCgroupLimits(tt.root)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCgroupLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCgroupLimits() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== formatCgroupLimits =====================
+func TestFormatCgroupLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		limits      CgroupLimits
+		wantContain []string
+		success     bool
+	}{
+		{
+			name: "success: limited pod",
+			limits: CgroupLimits{
+				Version: 2, Path: "/kubepods/pod123/ctr", MemoryLimitBytes: 512 << 20, MemoryUsageBytes: 120 << 20,
+				MemoryPeakBytes: 200 << 20, CPUQuotaUsec: 50000, CPUPeriodUsec: 100000, PidsLimit: 1024, PidsCurrent: 12,
+				NrPeriods: 340, NrThrottled: 12, ThrottledUsec: 1200000,
+			},
+			wantContain: []string{
+				"Cgroup: v2 /kubepods/pod123/ctr",
+				"Container memory: usage 120.00 MiB, peak 200.00 MiB, limit 512.00 MiB (6% of host)",
+				"Container CPU: quota 0.50 cores (50000/100000us), host 8 CPUs; throttled 12 of 340 periods (1.2s)",
+				"Container pids: 12 of 1024",
+				"Container io.max: none",
+			},
+			success: true,
+		},
+		{
+			name:   "success: unlimited",
+			limits: CgroupLimits{Version: 1, Path: "/", MemoryLimitBytes: -1, MemoryUsageBytes: -1, MemoryPeakBytes: -1, CPUQuotaUsec: -1, PidsLimit: -1, PidsCurrent: -1},
+			wantContain: []string{
+				"Container memory: usage n/a, peak n/a, limit unlimited",
+				"Container CPU: quota unlimited",
+				"Container pids: n/a of unlimited",
+			},
+			success: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatCgroupLimits(tt.limits, 8192000, 8)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatCgroupLimits() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
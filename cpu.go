@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// notableCPUFlags are the /proc/cpuinfo flags reported in the machine info.
+var notableCPUFlags = []string{"avx2", "avx512f", "aes"}
+
+// CPUInfo combines /proc/cpuinfo with the sysfs CPU topology. Frequencies are
+// in MHz and are zero when the platform doesn't expose them.
+type CPUInfo struct {
+	ModelName      string   `json:"model_name"`
+	Vendor         string   `json:"vendor,omitempty"`
+	Sockets        int      `json:"sockets"`
+	PhysicalCores  int      `json:"physical_cores"`
+	ThreadsPerCore int      `json:"threads_per_core"`
+	Online         []int    `json:"online"`
+	Offline        []int    `json:"offline,omitempty"`
+	CurrentMHz     float64  `json:"current_mhz"`
+	MaxMHz         float64  `json:"max_mhz"`
+	Flags          []string `json:"flags"`
+	Affinity       []int    `json:"affinity"`
+}
+
+// HasFlag reports whether flag was listed in /proc/cpuinfo.
+func (c CPUInfo) HasFlag(flag string) bool {
+	for _, f := range c.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCPUList parses the kernel's cpulist format, e.g. "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpulist %q: %w", s, err)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil || end < start {
+				return nil, fmt.Errorf("invalid cpulist range %q", part)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// formatCPUList is the inverse of parseCPUList; cpus must be sorted.
+func formatCPUList(cpus []int) string {
+	var parts []string
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// cpuInfoFromData extracts the model, vendor, flags and average "cpu MHz"
+// from /proc/cpuinfo. ARM kernels use "Features" instead of "flags" and may
+// omit "model name".
+func cpuInfoFromData(data []byte) CPUInfo {
+	var info CPUInfo
+	var mhzSum float64
+	var mhzCount int
+	for _, line := range strings.Split(string(data), "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		switch key {
+		case "model name", "Model":
+			if info.ModelName == "" {
+				info.ModelName = val
+			}
+		case "vendor_id", "CPU implementer":
+			if info.Vendor == "" {
+				info.Vendor = val
+			}
+		case "flags", "Features":
+			if info.Flags == nil {
+				info.Flags = strings.Fields(val)
+			}
+		case "cpu MHz":
+			if mhz, err := strconv.ParseFloat(val, 64); err == nil {
+				mhzSum += mhz
+				mhzCount++
+			}
+		}
+	}
+	if mhzCount > 0 {
+		info.CurrentMHz = mhzSum / float64(mhzCount)
+	}
+	return info
+}
+
+// affinityFromStatus returns the Cpus_allowed_list of /proc/<pid>/status.
+func affinityFromStatus(data []byte) ([]int, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if val, ok := strings.CutPrefix(line, "Cpus_allowed_list:"); ok {
+			return parseCPUList(val)
+		}
+	}
+	return nil, fmt.Errorf("Cpus_allowed_list not found")
+}
+
+// readKHzAsMHz reads a cpufreq attribute (kHz) and returns MHz, or 0.
+func readKHzAsMHz(path string) float64 {
+	khz, err := strconv.ParseFloat(readSysfsString(path), 64)
+	if err != nil {
+		return 0
+	}
+	return khz / 1000
+}
+
+// readCPUInfo reads /proc/cpuinfo, the process affinity from /proc/self/status
+// and the topology and cpufreq data under <sysRoot>/devices/system/cpu.
+func readCPUInfo(sysRoot string) CPUInfo {
+	data, err := ReadFile("/proc/cpuinfo")
+	if err != nil {
+		fmt.Println("Reading /proc/cpuinfo failed:", err)
+	}
+	info := cpuInfoFromData(data)
+
+	if status, err := ReadFile("/proc/self/status"); err != nil {
+		fmt.Println("Reading /proc/self/status failed:", err)
+	} else if info.Affinity, err = affinityFromStatus(status); err != nil {
+		fmt.Println("Parsing CPU affinity failed:", err)
+	}
+
+	cpuDir := filepath.Join(sysRoot, "devices/system/cpu")
+	if info.Online, err = parseCPUList(readSysfsString(filepath.Join(cpuDir, "online"))); err != nil {
+		fmt.Println("Parsing online CPUs failed:", err)
+	}
+	if info.Offline, err = parseCPUList(readSysfsString(filepath.Join(cpuDir, "offline"))); err != nil {
+		fmt.Println("Parsing offline CPUs failed:", err)
+	}
+
+	packages := make(map[string]bool)
+	threadsPerCore := make(map[string]int)
+	var curSum float64
+	var curCount int
+	for _, cpu := range info.Online {
+		dir := filepath.Join(cpuDir, fmt.Sprintf("cpu%d", cpu))
+		pkg := readSysfsString(filepath.Join(dir, "topology/physical_package_id"))
+		core := readSysfsString(filepath.Join(dir, "topology/core_id"))
+		if pkg != "" && core != "" {
+			packages[pkg] = true
+			threadsPerCore[pkg+":"+core]++
+		}
+		if cur := readKHzAsMHz(filepath.Join(dir, "cpufreq/scaling_cur_freq")); cur > 0 {
+			curSum += cur
+			curCount++
+		}
+		if max := readKHzAsMHz(filepath.Join(dir, "cpufreq/cpuinfo_max_freq")); max > info.MaxMHz {
+			info.MaxMHz = max
+		}
+	}
+	info.Sockets = len(packages)
+	info.PhysicalCores = len(threadsPerCore)
+	for _, n := range threadsPerCore {
+		if n > info.ThreadsPerCore {
+			info.ThreadsPerCore = n
+		}
+	}
+	if curCount > 0 {
+		info.CurrentMHz = curSum / float64(curCount)
+	}
+	return info
+}
+
+// formatCPUInfo renders CPUInfo as the machine info lines following "CPU cores:".
+func formatCPUInfo(info CPUInfo) string {
+	var present, missing []string
+	for _, flag := range notableCPUFlags {
+		if info.HasFlag(flag) {
+			present = append(present, flag)
+		} else {
+			missing = append(missing, flag)
+		}
+	}
+	sort.Strings(present)
+	sort.Strings(missing)
+
+	lines := []string{
+		fmt.Sprintf("CPU model: %s", info.ModelName),
+		fmt.Sprintf("CPU topology: %d socket(s), %d core(s), %d thread(s) per core",
+			info.Sockets, info.PhysicalCores, info.ThreadsPerCore),
+		fmt.Sprintf("CPUs online: %s, offline: %s", orNone(formatCPUList(info.Online)), orNone(formatCPUList(info.Offline))),
+		fmt.Sprintf("CPU affinity: %s (%d of %d online)", orNone(formatCPUList(info.Affinity)), len(info.Affinity), len(info.Online)),
+		fmt.Sprintf("CPU frequency: current %.0f MHz, max %.0f MHz", info.CurrentMHz, info.MaxMHz),
+		fmt.Sprintf("CPU flags: %s (missing: %s)", orNone(strings.Join(present, " ")), orNone(strings.Join(missing, " "))),
+	}
+	return strings.Join(lines, "\n")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================== parseCPUList / formatCPUList =====================
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []int
+		wantErr bool
+		success bool
+	}{
+		{name: "success: single cpu", input: "0", want: []int{0}, success: true},
+		{name: "success: range", input: "0-3\n", want: []int{0, 1, 2, 3}, success: true},
+		{name: "success: mixed", input: "0-1,4,6-7", want: []int{0, 1, 4, 6, 7}, success: true},
+		{name: "success: empty (no offline cpus)", input: "\n", want: nil, success: true},
+		{name: "failure: not a number", input: "a-b", wantErr: true, success: false},
+		{name: "failure: reversed range", input: "3-1", wantErr: true, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCPUList(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCPUList(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCPUList(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if !tt.wantErr && formatCPUList(got) != strings.TrimSpace(tt.input) {
+				t.Errorf("formatCPUList(%v) = %q, want %q", got, formatCPUList(got), strings.TrimSpace(tt.input))
+			}
+		})
+	}
+}
+
+// ===================== cpuInfoFromData =====================
+func TestCPUInfoFromData(t *testing.T) {
+	x86, err := os.ReadFile("testdata/proc/cpuinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arm, err := os.ReadFile("testdata/proc/cpuinfo_arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		input     []byte
+		wantModel string
+		wantMHz   float64
+		wantFlags []string
+		noFlags   []string
+		success   bool
+	}{
+		{
+			name:      "success: x86 cpuinfo",
+			input:     x86,
+			wantModel: "Intel(R) Xeon(R) Gold 6230 CPU @ 2.10GHz",
+			wantMHz:   2100,
+			wantFlags: []string{"avx2", "aes"},
+			noFlags:   []string{"avx512f"},
+			success:   true,
+		},
+		{
+			name:      "success: arm64 cpuinfo uses Features",
+			input:     arm,
+			wantModel: "",
+			wantMHz:   0,
+			wantFlags: []string{"aes", "sha2"},
+			noFlags:   []string{"avx2"},
+			success:   true,
+		},
+		{
+			name:    "failure: empty input",
+			input:   nil,
+			noFlags: []string{"aes"},
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cpuInfoFromData(tt.input)
+			if got.ModelName != tt.wantModel {
+				t.Errorf("ModelName = %q, want %q", got.ModelName, tt.wantModel)
+			}
+			if got.CurrentMHz != tt.wantMHz {
+				t.Errorf("CurrentMHz = %v, want %v", got.CurrentMHz, tt.wantMHz)
+			}
+			for _, f := range tt.wantFlags {
+				if !got.HasFlag(f) {
+					t.Errorf("HasFlag(%q) = false, want true", f)
+				}
+			}
+			for _, f := range tt.noFlags {
+				if got.HasFlag(f) {
+					t.Errorf("HasFlag(%q) = true, want false", f)
+				}
+			}
+		})
+	}
+}
+
+// ===================== readCPUInfo (fake sysfs in testdata) =====================
+func TestReadCPUInfo(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	tests := []struct {
+		name         string
+		sysRoot      string
+		statusErr    error
+		wantSockets  int
+		wantCores    int
+		wantThreads  int
+		wantOnline   string
+		wantOffline  string
+		wantAffinity string
+		wantCurMHz   float64
+		wantMaxMHz   float64
+		success      bool
+	}{
+		{
+			name:         "success: 1 socket, 4 cores, SMT2, cpu7 offline",
+			sysRoot:      "testdata/sys",
+			wantSockets:  1,
+			wantCores:    4,
+			wantThreads:  2,
+			wantOnline:   "0-6",
+			wantOffline:  "7",
+			wantAffinity: "0-3",
+			wantCurMHz:   2300,
+			wantMaxMHz:   3500,
+			success:      true,
+		},
+		{
+			name:         "failure: no sysfs falls back to cpuinfo MHz",
+			sysRoot:      "testdata/does-not-exist",
+			wantAffinity: "0-3",
+			wantCurMHz:   2100,
+			success:      false,
+		},
+		{
+			name:        "failure: status unreadable leaves affinity empty",
+			sysRoot:     "testdata/sys",
+			statusErr:   errors.New("permission denied"),
+			wantSockets: 1,
+			wantCores:   4,
+			wantThreads: 2,
+			wantOnline:  "0-6",
+			wantOffline: "7",
+			wantCurMHz:  2300,
+			wantMaxMHz:  3500,
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				switch path {
+				case "/proc/cpuinfo":
+					return os.ReadFile("testdata/proc/cpuinfo")
+				case "/proc/self/status":
+					if tt.statusErr != nil {
+						return nil, tt.statusErr
+					}
+					return os.ReadFile("testdata/proc/status")
+				}
+				return os.ReadFile(path)
+			}
+			got := readCPUInfo(tt.sysRoot)
+			if got.Sockets != tt.wantSockets || got.PhysicalCores != tt.wantCores || got.ThreadsPerCore != tt.wantThreads {
+				t.Errorf("topology = %d/%d/%d, want %d/%d/%d", got.Sockets, got.PhysicalCores, got.ThreadsPerCore,
+					tt.wantSockets, tt.wantCores, tt.wantThreads)
+			}
+			if formatCPUList(got.Online) != tt.wantOnline || formatCPUList(got.Offline) != tt.wantOffline {
+				t.Errorf("online/offline = %q/%q, want %q/%q", formatCPUList(got.Online), formatCPUList(got.Offline),
+					tt.wantOnline, tt.wantOffline)
+			}
+			if formatCPUList(got.Affinity) != tt.wantAffinity {
+				t.Errorf("affinity = %q, want %q", formatCPUList(got.Affinity), tt.wantAffinity)
+			}
+			if got.CurrentMHz != tt.wantCurMHz || got.MaxMHz != tt.wantMaxMHz {
+				t.Errorf("MHz = %v/%v, want %v/%v", got.CurrentMHz, got.MaxMHz, tt.wantCurMHz, tt.wantMaxMHz)
+			}
+		})
+	}
+}
+
+// ===================== formatCPUInfo =====================
+func TestFormatCPUInfo(t *testing.T) {
+	info := CPUInfo{
+		ModelName: "Test CPU", Sockets: 1, PhysicalCores: 4, ThreadsPerCore: 2,
+		Online: []int{0, 1, 2, 3, 4, 5, 6}, Offline: []int{7}, Affinity: []int{0, 1, 2, 3},
+		CurrentMHz: 2300, MaxMHz: 3500, Flags: []string{"sse", "avx2", "aes"},
+	}
+	got := formatCPUInfo(info)
+	for _, want := range []string{
+		"CPU model: Test CPU",
+		"CPU topology: 1 socket(s), 4 core(s), 2 thread(s) per core",
+		"CPUs online: 0-6, offline: 7",
+		"CPU affinity: 0-3 (4 of 7 online)",
+		"CPU frequency: current 2300 MHz, max 3500 MHz",
+		"CPU flags: aes avx2 (missing: avx512f)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatCPUInfo() = %q, want to contain %q", got, want)
+		}
+	}
+}
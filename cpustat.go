@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sleep is mockable in tests.
+var Sleep = time.Sleep
+
+// CPUTimes are the cumulative jiffies of one "cpu" line of /proc/stat. Guest
+// time is already included in User and Nice, so it is not tracked separately.
+type CPUTimes struct {
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+func (t CPUTimes) total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// ProcStat is the subset of /proc/stat we sample. CPUs is keyed by the line
+// label: "cpu" for the aggregate and "cpuN" for each online CPU.
+type ProcStat struct {
+	CPUs         map[string]CPUTimes
+	ProcsRunning int
+	ProcsBlocked int
+}
+
+// CPUUsage is the share of time, in percent, a CPU spent in each state
+// between two /proc/stat samples. System includes irq and softirq.
+type CPUUsage struct {
+	CPU    string  `json:"cpu"`
+	User   float64 `json:"user"`
+	System float64 `json:"system"`
+	IOWait float64 `json:"iowait"`
+	Steal  float64 `json:"steal"`
+	Idle   float64 `json:"idle"`
+}
+
+// LoadAvg holds /proc/loadavg plus the instantaneous process counts from /proc/stat.
+type LoadAvg struct {
+	Load1        float64 `json:"load1"`
+	Load5        float64 `json:"load5"`
+	Load15       float64 `json:"load15"`
+	Runnable     int     `json:"runnable"`
+	Tasks        int     `json:"tasks"`
+	ProcsRunning int     `json:"procs_running"`
+	ProcsBlocked int     `json:"procs_blocked"`
+}
+
+// parseProcStat parses the cpu and procs_* lines of /proc/stat.
+func parseProcStat(data []byte) (ProcStat, error) {
+	stat := ProcStat{CPUs: make(map[string]CPUTimes)}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(fields[0], "cpu"):
+			if len(fields) < 9 {
+				return ProcStat{}, fmt.Errorf("short cpu line %q", line)
+			}
+			var v [8]uint64
+			for i := range v {
+				n, err := strconv.ParseUint(fields[i+1], 10, 64)
+				if err != nil {
+					return ProcStat{}, fmt.Errorf("parsing %q: %w", line, err)
+				}
+				v[i] = n
+			}
+			stat.CPUs[fields[0]] = CPUTimes{v[0], v[1], v[2], v[3], v[4], v[5], v[6], v[7]}
+		case fields[0] == "procs_running":
+			stat.ProcsRunning, _ = strconv.Atoi(fields[1])
+		case fields[0] == "procs_blocked":
+			stat.ProcsBlocked, _ = strconv.Atoi(fields[1])
+		}
+	}
+	if _, ok := stat.CPUs["cpu"]; !ok {
+		return ProcStat{}, fmt.Errorf("no aggregate cpu line")
+	}
+	return stat, nil
+}
+
+// cpuUsageBetween computes per-CPU and aggregate usage between two samples.
+// The aggregate comes first, followed by CPUs in numeric order; CPUs missing
+// from either sample (hotplugged) or without elapsed ticks are skipped.
+func cpuUsageBetween(before, after ProcStat) []CPUUsage {
+	var usage []CPUUsage
+	for name, a := range after.CPUs {
+		b, ok := before.CPUs[name]
+		if !ok || a.total() <= b.total() {
+			continue
+		}
+		d := float64(a.total() - b.total())
+		pct := func(x, y uint64) float64 {
+			if x < y {
+				return 0
+			}
+			return float64(x-y) / d * 100
+		}
+		usage = append(usage, CPUUsage{
+			CPU:    name,
+			User:   pct(a.User+a.Nice, b.User+b.Nice),
+			System: pct(a.System+a.IRQ+a.SoftIRQ, b.System+b.IRQ+b.SoftIRQ),
+			IOWait: pct(a.IOWait, b.IOWait),
+			Steal:  pct(a.Steal, b.Steal),
+			Idle:   pct(a.Idle, b.Idle),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].CPU == "cpu" || usage[j].CPU == "cpu" {
+			return usage[i].CPU == "cpu"
+		}
+		ni, _ := strconv.Atoi(strings.TrimPrefix(usage[i].CPU, "cpu"))
+		nj, _ := strconv.Atoi(strings.TrimPrefix(usage[j].CPU, "cpu"))
+		return ni < nj
+	})
+	return usage
+}
+
+func readProcStat() (ProcStat, error) {
+	data, err := ReadFile("/proc/stat")
+	if err != nil {
+		return ProcStat{}, err
+	}
+	return parseProcStat(data)
+}
+
+// sampleCPUUsage reads /proc/stat twice, interval apart.
+func sampleCPUUsage(interval time.Duration) ([]CPUUsage, ProcStat, error) {
+	before, err := readProcStat()
+	if err != nil {
+		return nil, ProcStat{}, err
+	}
+	Sleep(interval)
+	after, err := readProcStat()
+	if err != nil {
+		return nil, ProcStat{}, err
+	}
+	return cpuUsageBetween(before, after), after, nil
+}
+
+// parseLoadAvg parses /proc/loadavg, e.g. "0.52 0.58 0.59 3/1234 56789".
+func parseLoadAvg(data []byte) (LoadAvg, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) < 4 {
+		return LoadAvg{}, fmt.Errorf("unexpected /proc/loadavg content %q", string(data))
+	}
+	var l LoadAvg
+	var err error
+	for i, dst := range []*float64{&l.Load1, &l.Load5, &l.Load15} {
+		if *dst, err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return LoadAvg{}, fmt.Errorf("parsing load average: %w", err)
+		}
+	}
+	runnable, tasks, ok := strings.Cut(fields[3], "/")
+	if !ok {
+		return LoadAvg{}, fmt.Errorf("unexpected task counts %q", fields[3])
+	}
+	if l.Runnable, err = strconv.Atoi(runnable); err != nil {
+		return LoadAvg{}, fmt.Errorf("parsing runnable tasks: %w", err)
+	}
+	if l.Tasks, err = strconv.Atoi(tasks); err != nil {
+		return LoadAvg{}, fmt.Errorf("parsing task count: %w", err)
+	}
+	return l, nil
+}
+
+func readLoadAvg() (LoadAvg, error) {
+	data, err := ReadFile("/proc/loadavg")
+	if err != nil {
+		return LoadAvg{}, err
+	}
+	return parseLoadAvg(data)
+}
+
+func formatCPUUsage(u CPUUsage) string {
+	return fmt.Sprintf("user %.1f%% system %.1f%% iowait %.1f%% steal %.1f%% idle %.1f%%",
+		u.User, u.System, u.IOWait, u.Steal, u.Idle)
+}
+
+// readCPULoad samples utilization over interval and reads the load averages,
+// rendered for the machine info output.
+func readCPULoad(interval time.Duration) string {
+	var lines []string
+	usage, stat, err := sampleCPUUsage(interval)
+	if err != nil {
+		lines = append(lines, "Sampling /proc/stat failed: "+err.Error())
+	}
+	for _, u := range usage {
+		label := u.CPU
+		if label == "cpu" {
+			label = "all"
+		}
+		lines = append(lines, fmt.Sprintf("CPU usage %s (%s): %s", label, interval, formatCPUUsage(u)))
+	}
+
+	load, err := readLoadAvg()
+	if err != nil {
+		lines = append(lines, "Reading /proc/loadavg failed: "+err.Error())
+	} else {
+		load.ProcsRunning, load.ProcsBlocked = stat.ProcsRunning, stat.ProcsBlocked
+		lines = append(lines, fmt.Sprintf("Load average: %.2f %.2f %.2f, runnable %d/%d tasks, procs running %d blocked %d",
+			load.Load1, load.Load5, load.Load15, load.Runnable, load.Tasks, load.ProcsRunning, load.ProcsBlocked))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatProcedureCPUUsage reports aggregate utilization between two samples
+// taken around a procedure, or "" if either sample is missing.
+func formatProcedureCPUUsage(before, after ProcStat) string {
+	for _, u := range cpuUsageBetween(before, after) {
+		if u.CPU == "cpu" {
+			return "CPU usage during procedure: " + formatCPUUsage(u)
+		}
+	}
+	return ""
+}
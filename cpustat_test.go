@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const procStatBefore = `cpu  1000 0 500 8000 100 0 0 0 0 0
+cpu0 500 0 250 4000 50 0 0 0 0 0
+cpu1 500 0 250 4000 50 0 0 0 0 0
+intr 123456 0 0
+ctxt 987654
+procs_running 1
+procs_blocked 0
+`
+
+const procStatAfter = `cpu  1100 20 560 8200 200 10 10 100 0 0
+cpu0 560 10 280 4100 100 5 5 40 0 0
+cpu1 540 10 280 4100 100 5 5 60 0 0
+intr 123999 0 0
+ctxt 999999
+procs_running 3
+procs_blocked 2
+`
+
+// ===================== parseProcStat =====================
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantCPUs    int
+		wantRunning int
+		wantBlocked int
+		wantErr     bool
+		success     bool
+	}{
+		{name: "success: aggregate and two cpus", input: procStatAfter, wantCPUs: 3, wantRunning: 3, wantBlocked: 2, success: true},
+		{name: "failure: short cpu line", input: "cpu 1 2 3\n", wantErr: true, success: false},
+		{name: "failure: non-numeric value", input: "cpu 1 2 3 x 5 6 7 8\n", wantErr: true, success: false},
+		{name: "failure: no aggregate line", input: "cpu0 1 2 3 4 5 6 7 8\n", wantErr: true, success: false},
+		{name: "failure: empty input", input: "", wantErr: true, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProcStat([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProcStat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.CPUs) != tt.wantCPUs || got.ProcsRunning != tt.wantRunning || got.ProcsBlocked != tt.wantBlocked {
+				t.Errorf("parseProcStat() = %d cpus, running %d, blocked %d; want %d, %d, %d",
+					len(got.CPUs), got.ProcsRunning, got.ProcsBlocked, tt.wantCPUs, tt.wantRunning, tt.wantBlocked)
+			}
+		})
+	}
+}
+
+// ===================== cpuUsageBetween =====================
+func TestCPUUsageBetween(t *testing.T) {
+	before, err := parseProcStat([]byte(procStatBefore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := parseProcStat([]byte(procStatAfter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage := cpuUsageBetween(before, after)
+	if len(usage) != 3 || usage[0].CPU != "cpu" || usage[1].CPU != "cpu0" || usage[2].CPU != "cpu1" {
+		t.Fatalf("cpuUsageBetween() order = %+v, want cpu, cpu0, cpu1", usage)
+	}
+	// Aggregate delta: user 100+nice 20, system 60+irq 10+softirq 10, idle 200, iowait 100, steal 100 = 600 ticks.
+	want := CPUUsage{CPU: "cpu", User: 20, System: 80.0 / 6, IOWait: 100.0 / 6, Steal: 100.0 / 6, Idle: 200.0 / 6}
+	got := usage[0]
+	for _, c := range []struct {
+		field     string
+		got, want float64
+	}{
+		{"user", got.User, want.User}, {"system", got.System, want.System}, {"iowait", got.IOWait, want.IOWait},
+		{"steal", got.Steal, want.Steal}, {"idle", got.Idle, want.Idle},
+	} {
+		if diff := c.got - c.want; diff > 0.001 || diff < -0.001 {
+			t.Errorf("aggregate %s = %.3f, want %.3f", c.field, c.got, c.want)
+		}
+	}
+
+	if got := cpuUsageBetween(after, after); len(got) != 0 {
+		t.Errorf("cpuUsageBetween(same, same) = %+v, want none", got)
+	}
+	if got := cpuUsageBetween(ProcStat{}, after); len(got) != 0 {
+		t.Errorf("cpuUsageBetween(empty, after) = %+v, want none", got)
+	}
+}
+
+// ===================== parseLoadAvg =====================
+func TestParseLoadAvg(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    LoadAvg
+		wantErr bool
+		success bool
+	}{
+		{name: "success: normal", input: "0.52 0.58 0.59 3/1234 56789\n", want: LoadAvg{Load1: 0.52, Load5: 0.58, Load15: 0.59, Runnable: 3, Tasks: 1234}, success: true},
+		{name: "failure: too few fields", input: "0.52 0.58\n", wantErr: true, success: false},
+		{name: "failure: bad load", input: "x 0.58 0.59 3/1234 1\n", wantErr: true, success: false},
+		{name: "failure: bad task counts", input: "0.52 0.58 0.59 3-1234 1\n", wantErr: true, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoadAvg([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLoadAvg() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseLoadAvg() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== readCPULoad =====================
+func TestReadCPULoad(t *testing.T) {
+	oldReadFile, oldSleep := ReadFile, Sleep
+	defer func() { ReadFile, Sleep = oldReadFile, oldSleep }()
+
+	tests := []struct {
+		name        string
+		statErr     error
+		loadErr     error
+		wantContain []string
+		success     bool
+	}{
+		{
+			name: "success: two samples and loadavg",
+			wantContain: []string{
+				"CPU usage all (1s): user 20.0% system 13.3% iowait 16.7% steal 16.7% idle 33.3%",
+				"CPU usage cpu0 (1s):",
+				"CPU usage cpu1 (1s):",
+				"Load average: 0.52 0.58 0.59, runnable 3/1234 tasks, procs running 3 blocked 2",
+			},
+			success: true,
+		},
+		{
+			name:        "failure: /proc/stat unreadable",
+			statErr:     errors.New("permission denied"),
+			wantContain: []string{"Sampling /proc/stat failed: permission denied", "Load average: 0.52"},
+			success:     false,
+		},
+		{
+			name:        "failure: /proc/loadavg unreadable",
+			loadErr:     errors.New("no such file"),
+			wantContain: []string{"CPU usage all", "Reading /proc/loadavg failed: no such file"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statReads := 0
+			var slept time.Duration
+			Sleep = func(d time.Duration) { slept = d }
+			ReadFile = func(path string) ([]byte, error) {
+				switch path {
+				case "/proc/stat":
+					if tt.statErr != nil {
+						return nil, tt.statErr
+					}
+					statReads++
+					if statReads == 1 {
+						return []byte(procStatBefore), nil
+					}
+					return []byte(procStatAfter), nil
+				case "/proc/loadavg":
+					return []byte("0.52 0.58 0.59 3/1234 56789\n"), tt.loadErr
+				}
+				return nil, errors.New("unexpected path")
+			}
+
+			got := readCPULoad(time.Second)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("readCPULoad() = %q, want to contain %q", got, want)
+				}
+			}
+			if tt.statErr == nil && slept != time.Second {
+				t.Errorf("slept %v between samples, want 1s", slept)
+			}
+		})
+	}
+}
+
+func TestFormatProcedureCPUUsage(t *testing.T) {
+	before, _ := parseProcStat([]byte(procStatBefore))
+	after, _ := parseProcStat([]byte(procStatAfter))
+	if got := formatProcedureCPUUsage(before, after); !strings.HasPrefix(got, "CPU usage during procedure: user 20.0%") {
+		t.Errorf("formatProcedureCPUUsage() = %q", got)
+	}
+	if got := formatProcedureCPUUsage(ProcStat{}, after); got != "" {
+		t.Errorf("formatProcedureCPUUsage(missing before) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseCron(t *testing.T, expr string) *CronExpr {
+	t.Helper()
+	c, err := parseCron(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// ===================== CronExpr.Next =====================
+func TestCronNext(t *testing.T) {
+	// 2024-05-01 was a Wednesday.
+	from := time.Date(2024, 5, 1, 12, 7, 30, 0, time.UTC)
+	tests := []struct {
+		name    string
+		expr    string
+		want    time.Time
+		success bool
+	}{
+		{
+			name:    "success: every minute is strictly after",
+			expr:    "* * * * *",
+			want:    time.Date(2024, 5, 1, 12, 8, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "success: step in minutes",
+			expr:    "*/15 * * * *",
+			want:    time.Date(2024, 5, 1, 12, 15, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "success: list and range of hours rolls to the next day",
+			expr:    "30 2,4-5 * * *",
+			want:    time.Date(2024, 5, 2, 2, 30, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "success: macro",
+			expr:    "@daily",
+			want:    time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "success: day of week only, 7 is Sunday",
+			expr:    "0 3 * * 7",
+			want:    time.Date(2024, 5, 5, 3, 0, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "success: day of month or day of week when both are restricted",
+			expr:    "0 0 10 * 5",
+			want:    time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "success: month and day roll over the year",
+			expr:    "0 0 29 2 *",
+			want:    time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+			success: true,
+		},
+		{
+			name:    "failure: never matches",
+			expr:    "0 0 31 2 *",
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustParseCron(t, tt.expr).Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== parseCron =====================
+func TestParseCron_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+		success bool
+	}{
+		{name: "failure: too few fields", expr: "* * * *", wantErr: "want 5 fields, got 4", success: false},
+		{name: "failure: out of range", expr: "60 * * * *", wantErr: `minute: "60" out of range 0-59`, success: false},
+		{name: "failure: reversed range", expr: "* 5-2 * * *", wantErr: `hour: "5-2" out of range`, success: false},
+		{name: "failure: zero step", expr: "*/0 * * * *", wantErr: `invalid step in "*/0"`, success: false},
+		{name: "failure: names are not supported", expr: "* * * jan *", wantErr: `month: invalid value "jan"`, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCron(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseCron(%q) error = %v, want to contain %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dmiPlaceholders are values firmware vendors leave in unset SMBIOS fields.
+var dmiPlaceholders = map[string]bool{
+	"To Be Filled By O.E.M.": true,
+	"Default string":         true,
+	"Not Specified":          true,
+	"Not Applicable":         true,
+	"System Serial Number":   true,
+	"System Product Name":    true,
+	"0123456789":             true,
+}
+
+// chassisTypes maps SMBIOS chassis type codes (DSP0134 7.4.1) to names.
+var chassisTypes = []string{
+	1: "Other", 2: "Unknown", 3: "Desktop", 4: "Low Profile Desktop", 5: "Pizza Box",
+	6: "Mini Tower", 7: "Tower", 8: "Portable", 9: "Laptop", 10: "Notebook",
+	11: "Hand Held", 12: "Docking Station", 13: "All in One", 14: "Sub Notebook",
+	15: "Space-saving", 16: "Lunch Box", 17: "Main Server Chassis", 18: "Expansion Chassis",
+	19: "SubChassis", 20: "Bus Expansion Chassis", 21: "Peripheral Chassis", 22: "RAID Chassis",
+	23: "Rack Mount Chassis", 24: "Sealed-case PC", 25: "Multi-system Chassis", 26: "Compact PCI",
+	27: "Advanced TCA", 28: "Blade", 29: "Blade Enclosure", 30: "Tablet", 31: "Convertible",
+	32: "Detachable", 33: "IoT Gateway", 34: "Embedded PC", 35: "Mini PC", 36: "Stick PC",
+}
+
+// dmiVMProducts maps sys_vendor or product_name prefixes set by hypervisors
+// to the hypervisor name.
+var dmiVMProducts = []struct{ prefix, name string }{
+	{"QEMU", "QEMU"},
+	{"KVM", "KVM"},
+	{"Standard PC (", "QEMU"},
+	{"VMware", "VMware"},
+	{"VirtualBox", "VirtualBox"},
+	{"innotek GmbH", "VirtualBox"},
+	{"Xen", "Xen"},
+	{"Amazon EC2", "Amazon EC2"},
+	{"Google Compute Engine", "Google Compute Engine"},
+	{"Virtual Machine", "Hyper-V"},
+	{"BHYVE", "bhyve"},
+	{"Parallels", "Parallels"},
+	{"OpenStack", "OpenStack"},
+}
+
+// DMIInfo is the hardware identity from /sys/class/dmi/id. Restricted lists
+// fields that exist but are readable by root only (serials and the UUID).
+type DMIInfo struct {
+	Available      bool     `json:"available"`
+	SysVendor      string   `json:"sys_vendor,omitempty"`
+	ProductName    string   `json:"product_name,omitempty"`
+	ProductVersion string   `json:"product_version,omitempty"`
+	ProductSerial  string   `json:"product_serial,omitempty"`
+	ProductUUID    string   `json:"product_uuid,omitempty"`
+	BoardVendor    string   `json:"board_vendor,omitempty"`
+	BoardName      string   `json:"board_name,omitempty"`
+	BoardSerial    string   `json:"board_serial,omitempty"`
+	BIOSVendor     string   `json:"bios_vendor,omitempty"`
+	BIOSVersion    string   `json:"bios_version,omitempty"`
+	BIOSDate       string   `json:"bios_date,omitempty"`
+	ChassisType    string   `json:"chassis_type,omitempty"`
+	ChassisVendor  string   `json:"chassis_vendor,omitempty"`
+	ChassisSerial  string   `json:"chassis_serial,omitempty"`
+	Restricted     []string `json:"restricted,omitempty"`
+	VirtualMachine string   `json:"virtual_machine,omitempty"` // hypervisor named by DMI, if any
+}
+
+// chassisTypeName converts the numeric chassis_type attribute.
+func chassisTypeName(s string) string {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n >= len(chassisTypes) {
+		return s
+	}
+	return chassisTypes[n]
+}
+
+// dmiVirtualMachine returns the hypervisor named by the DMI vendor or
+// product, or "" on physical hardware.
+func dmiVirtualMachine(d DMIInfo) string {
+	for _, s := range []string{d.SysVendor, d.ProductName} {
+		for _, vm := range dmiVMProducts {
+			if strings.HasPrefix(s, vm.prefix) {
+				return vm.name
+			}
+		}
+	}
+	return ""
+}
+
+// readDMIInfo reads <sysRoot>/class/dmi/id. Missing attributes and firmware
+// placeholders are left empty; permission errors are recorded in Restricted.
+func readDMIInfo(sysRoot string) DMIInfo {
+	dir := filepath.Join(sysRoot, "class/dmi/id")
+	d := DMIInfo{Available: fileExists(dir)}
+	if !d.Available {
+		return d
+	}
+
+	for _, f := range []struct {
+		name string
+		dst  *string
+	}{
+		{"sys_vendor", &d.SysVendor},
+		{"product_name", &d.ProductName},
+		{"product_version", &d.ProductVersion},
+		{"product_serial", &d.ProductSerial},
+		{"product_uuid", &d.ProductUUID},
+		{"board_vendor", &d.BoardVendor},
+		{"board_name", &d.BoardName},
+		{"board_serial", &d.BoardSerial},
+		{"bios_vendor", &d.BIOSVendor},
+		{"bios_version", &d.BIOSVersion},
+		{"bios_date", &d.BIOSDate},
+		{"chassis_type", &d.ChassisType},
+		{"chassis_vendor", &d.ChassisVendor},
+		{"chassis_serial", &d.ChassisSerial},
+	} {
+		data, err := ReadFile(filepath.Join(dir, f.name))
+		if errors.Is(err, fs.ErrPermission) {
+			d.Restricted = append(d.Restricted, f.name)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if v := strings.TrimSpace(string(data)); !dmiPlaceholders[v] {
+			*f.dst = v
+		}
+	}
+	d.ChassisType = chassisTypeName(d.ChassisType)
+	d.VirtualMachine = dmiVirtualMachine(d)
+	return d
+}
+
+// dmiField renders a value, noting root-only fields we couldn't read.
+func dmiField(d DMIInfo, name, value string) string {
+	for _, r := range d.Restricted {
+		if r == name {
+			return "restricted"
+		}
+	}
+	return orNone(value)
+}
+
+// formatDMIInfo renders the hardware section of the machine info output.
+func formatDMIInfo(d DMIInfo) string {
+	if !d.Available {
+		return "Hardware: DMI not available (no /sys/class/dmi/id on this platform)"
+	}
+	system := strings.TrimSpace(strings.Join([]string{d.SysVendor, d.ProductName, d.ProductVersion}, " "))
+	if d.VirtualMachine != "" {
+		system += fmt.Sprintf(" (virtual machine: %s)", d.VirtualMachine)
+	}
+	lines := []string{
+		fmt.Sprintf("Hardware: %s serial=%s uuid=%s", orNone(system),
+			dmiField(d, "product_serial", d.ProductSerial), dmiField(d, "product_uuid", d.ProductUUID)),
+		fmt.Sprintf("Hardware board: %s serial=%s",
+			orNone(strings.TrimSpace(d.BoardVendor+" "+d.BoardName)), dmiField(d, "board_serial", d.BoardSerial)),
+		fmt.Sprintf("Hardware BIOS: %s %s (%s)", orNone(d.BIOSVendor), orNone(d.BIOSVersion), orNone(d.BIOSDate)),
+		fmt.Sprintf("Hardware chassis: %s %s serial=%s",
+			orNone(d.ChassisType), orNone(d.ChassisVendor), dmiField(d, "chassis_serial", d.ChassisSerial)),
+	}
+	if len(d.Restricted) > 0 {
+		lines = append(lines, "Hardware restricted fields (readable by root only): "+strings.Join(d.Restricted, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
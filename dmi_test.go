@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================== chassisTypeName =====================
+func TestChassisTypeName(t *testing.T) {
+	for in, want := range map[string]string{"23": "Rack Mount Chassis", "1": "Other", "99": "99", "": "", "x": "x"} {
+		if got := chassisTypeName(in); got != want {
+			t.Errorf("chassisTypeName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// ===================== readDMIInfo =====================
+func TestReadDMIInfo(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	dmiDir := filepath.Join("testdata/sys", "class/dmi/id")
+	tests := []struct {
+		name        string
+		sysRoot     string
+		denied      []string          // files that fail with EACCES
+		override    map[string]string // file contents replacing the fixture
+		wantVM      string
+		wantContain []string
+		success     bool
+	}{
+		{
+			name:    "success: physical server as root",
+			sysRoot: "testdata/sys",
+			wantContain: []string{
+				"Hardware: Dell Inc. PowerEdge R640 serial=7XK4J23 uuid=4c4c4544-0058-4b10-8034-b7c04f4a3233",
+				"Hardware board: Dell Inc. 0X45NX serial=.7XK4J23.CNIVC0095U0123.",
+				"Hardware BIOS: Dell Inc. 2.17.1 (11/15/2022)",
+				"Hardware chassis: Rack Mount Chassis Dell Inc. serial=7XK4J23",
+			},
+			success: true,
+		},
+		{
+			name:    "success: unprivileged reads of root-only fields",
+			sysRoot: "testdata/sys",
+			denied:  []string{"product_serial", "product_uuid", "board_serial", "chassis_serial"},
+			wantContain: []string{
+				"Hardware: Dell Inc. PowerEdge R640 serial=restricted uuid=restricted",
+				"Hardware board: Dell Inc. 0X45NX serial=restricted",
+				"Hardware restricted fields (readable by root only): product_serial, product_uuid, board_serial, chassis_serial",
+			},
+			success: true,
+		},
+		{
+			name:    "success: KVM guest with placeholder serial",
+			sysRoot: "testdata/sys",
+			override: map[string]string{
+				"sys_vendor": "QEMU\n", "product_name": "Standard PC (Q35 + ICH9, 2009)\n", "product_version": "pc-q35-8.2\n",
+				"product_serial": "Not Specified\n", "chassis_type": "1\n",
+			},
+			wantVM: "QEMU",
+			wantContain: []string{
+				"Hardware: QEMU Standard PC (Q35 + ICH9, 2009) pc-q35-8.2 (virtual machine: QEMU) serial=none",
+				"Hardware chassis: Other",
+			},
+			success: true,
+		},
+		{
+			name:        "failure: no DMI on this platform",
+			sysRoot:     "testdata/does-not-exist",
+			wantContain: []string{"Hardware: DMI not available"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				name := filepath.Base(path)
+				for _, d := range tt.denied {
+					if path == filepath.Join(dmiDir, d) {
+						return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+					}
+				}
+				if data, ok := tt.override[name]; ok {
+					return []byte(data), nil
+				}
+				return os.ReadFile(path)
+			}
+
+			d := readDMIInfo(tt.sysRoot)
+			if d.VirtualMachine != tt.wantVM {
+				t.Errorf("VirtualMachine = %q, want %q", d.VirtualMachine, tt.wantVM)
+			}
+			got := formatDMIInfo(d)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatDMIInfo() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
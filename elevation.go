@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Elevation is a strategy for running privileged steps. Prefix is prepended
+// to the argv of a privileged step; it is empty when we already have the
+// privileges we need. SwitchesUser is set for strategies that make us root
+// (so we get the bounding capability set rather than our effective one).
+// AllSteps is set for strategies that change what paths refer to, so
+// unprivileged steps must be wrapped as well to see the same files; Root is
+// where the filesystem those steps see is visible from the container.
+type Elevation struct {
+	Name         string   `json:"name"`
+	Prefix       []string `json:"prefix,omitempty"`
+	SwitchesUser bool     `json:"switches_user"`
+	AllSteps     bool     `json:"all_steps,omitempty"`
+	Root         string   `json:"root,omitempty"`
+}
+
+// elevations are the strategies selectable with -elevate. nsenter runs steps
+// in the mount namespace of PID 1, i.e. the node's when the pod has hostPID;
+// paths in those steps then refer to the node's filesystem, so it applies to
+// every step: a disk file created in the container would not exist for a
+// losetup or mount on the node.
+var elevations = map[string]Elevation{
+	"none":    {Name: "none"},
+	"sudo":    {Name: "sudo", Prefix: []string{"sudo", "-n"}, SwitchesUser: true},
+	"doas":    {Name: "doas", Prefix: []string{"doas", "-n"}, SwitchesUser: true},
+	"nsenter": {Name: "nsenter", Prefix: []string{"nsenter", "--target", "1", "--mount", "--"}, AllSteps: true, Root: "/proc/1/root"},
+}
+
+// elevation is the strategy applied by runCommand; main selects it from
+// -elevate. Tests run with none so commands appear unwrapped.
+var elevation = elevations["none"]
+
+// selectElevation resolves an -elevate value. "auto" needs nothing as root
+// and falls back to sudo otherwise.
+func selectElevation(name string, euid int) (Elevation, error) {
+	if name == "auto" {
+		if euid == 0 {
+			return elevations["none"], nil
+		}
+		return elevations["sudo"], nil
+	}
+	e, ok := elevations[name]
+	if !ok {
+		names := make([]string, 0, len(elevations))
+		for n := range elevations {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Elevation{}, fmt.Errorf("unknown elevation %q (want auto, %s)", name, strings.Join(names, ", "))
+	}
+	return e, nil
+}
+
+// Argv returns argv elevated by this strategy.
+func (e Elevation) Argv(argv ...string) []string {
+	return append(append([]string(nil), e.Prefix...), argv...)
+}
+
+// LookPath resolves file the way a step run by this strategy will find it:
+// on PATH in the container, or, with a Root, on the same PATH in the
+// filesystem under Root. The returned path is the one the step sees.
+func (e Elevation) LookPath(file string) (string, error) {
+	if e.Root == "" {
+		return LookPath(file)
+	}
+	candidates := []string{file}
+	if !strings.Contains(file, "/") {
+		candidates = nil
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			if filepath.IsAbs(dir) {
+				candidates = append(candidates, filepath.Join(dir, file))
+			}
+		}
+	}
+	for _, path := range candidates {
+		// Absolute symlinks under Root would resolve in the container, so a
+		// symlink is taken as is.
+		fi, err := os.Lstat(filepath.Join(e.Root, path))
+		if err == nil && (fi.Mode()&os.ModeSymlink != 0 || fi.Mode().IsRegular() && fi.Mode()&0o111 != 0) {
+			return path, nil
+		}
+	}
+	return "", errors.New("executable file not found in $PATH under " + e.Root)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// ===================== selectElevation =====================
+func TestSelectElevation(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		euid     int
+		wantName string
+		wantErr  string
+		success  bool
+	}{
+		{
+			name:     "success: auto as root needs nothing",
+			input:    "auto",
+			wantName: "none",
+			success:  true,
+		},
+		{
+			name:     "success: auto as non-root falls back to sudo",
+			input:    "auto",
+			euid:     1000,
+			wantName: "sudo",
+			success:  true,
+		},
+		{
+			name:     "success: explicit nsenter as root",
+			input:    "nsenter",
+			wantName: "nsenter",
+			success:  true,
+		},
+		{
+			name:    "failure: unknown strategy",
+			input:   "su",
+			euid:    1000,
+			wantErr: `unknown elevation "su" (want auto, doas, none, nsenter, sudo)`,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectElevation(tt.input, tt.euid)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("selectElevation() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectElevation() unexpected error: %v", err)
+			}
+			if got.Name != tt.wantName {
+				t.Errorf("selectElevation() = %q, want %q", got.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+// ===================== stepArgv / runCommand =====================
+func TestRunCommand_Elevation(t *testing.T) {
+	oldRun, oldElevation := RunArgv, elevation
+	defer func() { RunArgv, elevation = oldRun, oldElevation }()
+
+	tests := []struct {
+		name      string
+		elevation string
+		step      Step
+		want      []string
+		success   bool
+	}{
+		{
+			name:      "success: none runs privileged steps as they are",
+			elevation: "none",
+			step:      privCmd("mount", "-o", "loop", "disk1", "/mnt/disk1"),
+			want:      []string{"mount", "-o", "loop", "disk1", "/mnt/disk1"},
+			success:   true,
+		},
+		{
+			name:      "success: sudo runs shell steps in its own bash",
+			elevation: "sudo",
+			step:      privShellCmd(`printf "Hello ext4\n" > "$1"`, "/mnt/disk1/test.txt"),
+			want:      []string{"sudo", "-n", "bash", "-c", `printf "Hello ext4\n" > "$1"`, "bash", "/mnt/disk1/test.txt"},
+			success:   true,
+		},
+		{
+			name:      "success: doas prefixes argv",
+			elevation: "doas",
+			step:      privCmd("losetup", "-d", "/dev/loop0"),
+			want:      []string{"doas", "-n", "losetup", "-d", "/dev/loop0"},
+			success:   true,
+		},
+		{
+			name:      "success: nsenter runs unprivileged steps in the node's namespace too",
+			elevation: "nsenter",
+			step:      cmd("cat", "/mnt/disk1/test.txt"),
+			want:      []string{"nsenter", "--target", "1", "--mount", "--", "cat", "/mnt/disk1/test.txt"},
+			success:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elevation = elevations[tt.elevation]
+			var got []string
+			RunArgv = func(argv []string, _ *Recorder) error {
+				got = argv
+				return nil
+			}
+			if err := runCommand(tt.step, nil); err != nil {
+				t.Fatalf("runCommand() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunArgv(%q), want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Run event types.
+const (
+	EventStepStarted  = "step-started"
+	EventStepOutput   = "step-output"
+	EventStepFinished = "step-finished"
+	EventRunFinished  = "run-finished"
+
+	EventOutputTruncated = "output-truncated"
+)
+
+// maxOutputEvents caps the step-output events kept per run: past it, one
+// output-truncated event replaces the rest of the output. Only the newest
+// run keeps them at all; Start drops those of the run before it, so the
+// runs kept for GET /v1/runs/{id} replay just their step and run events.
+const maxOutputEvents = 1000
+
+// RunEvent is one entry of a run's event log. Seq numbers start at 1 and are
+// the SSE event IDs, so a client can resume with Last-Event-ID. Step is the
+// 1-based index of the step in Run.Steps.
+type RunEvent struct {
+	Seq    int         `json:"seq"`
+	Type   string      `json:"type"`
+	Time   time.Time   `json:"time"`
+	Step   int         `json:"step,omitempty"`
+	Argv   []string    `json:"argv,omitempty"`
+	Stream string      `json:"stream,omitempty"`
+	Line   string      `json:"line,omitempty"`
+	Result *StepResult `json:"result,omitempty"`
+	Status string      `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// emit appends ev to the event log of run and wakes up its streams. The
+// caller holds m.mu.
+func (m *RunManager) emit(run *Run, ev RunEvent) {
+	if ev.Type == EventStepOutput {
+		run.outputEvents++
+		switch {
+		case run.outputEvents == maxOutputEvents+1:
+			ev = RunEvent{Type: EventOutputTruncated, Step: ev.Step}
+		case run.outputEvents > maxOutputEvents+1:
+			return
+		}
+	}
+	run.seq++
+	ev.Seq, ev.Time = run.seq, time.Now()
+	run.events = append(run.events, ev)
+	close(run.notify)
+	run.notify = make(chan struct{})
+}
+
+// trimOutput drops the output events of a finished run. Seq numbers are
+// kept, so a stream resuming with Last-Event-ID just skips the gap. The
+// caller holds m.mu.
+func (m *RunManager) trimOutput(run *Run) {
+	kept := run.events[:0:0]
+	for _, ev := range run.events {
+		if ev.Type != EventStepOutput && ev.Type != EventOutputTruncated {
+			kept = append(kept, ev)
+		}
+	}
+	run.events = kept
+}
+
+// Events returns the events of run id after seq, whether the run has
+// finished (so no more will come) and a channel closed on the next event.
+func (m *RunManager) Events(id string, after int) (events []RunEvent, done bool, notify <-chan struct{}, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.runs[id]
+	if !ok {
+		return nil, false, nil, false
+	}
+	i := sort.Search(len(run.events), func(i int) bool { return run.events[i].Seq > after })
+	events = append(events, run.events[i:]...)
+	return events, run.Status != RunRunning, run.notify, true
+}
+
+// streamEvents serves GET /v1/runs/{id}/events as Server-Sent Events: every
+// event emitted so far is replayed (after Last-Event-ID when reconnecting),
+// then new ones are sent as they happen until the run finishes.
+func (a *RunAPI) streamEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	after := 0
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid Last-Event-ID %q", v)})
+			return
+		}
+		after = n
+	}
+	events, done, notify, ok := a.runs.Events(id, after)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("run %q not found", id)})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: "streaming is not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		for _, ev := range events {
+			data, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, data)
+			after = ev.Seq
+		}
+		flusher.Flush()
+		if done {
+			return
+		}
+		select {
+		case <-notify:
+		case <-r.Context().Done():
+			return
+		}
+		if events, done, notify, ok = a.runs.Events(id, after); !ok {
+			return
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readSSE parses a Server-Sent Events stream into its events.
+func readSSE(t *testing.T, body string) []RunEvent {
+	t.Helper()
+	var events []RunEvent
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev RunEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			t.Fatalf("invalid event %q: %v", data, err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func eventTypes(events []RunEvent) []string {
+	types := make([]string, len(events))
+	for i, ev := range events {
+		types[i] = ev.Type
+	}
+	return types
+}
+
+// ===================== GET /v1/runs/{id}/events =====================
+func TestStreamEvents_Replay(t *testing.T) {
+	m := newRunManager()
+	run, _ := m.Start("lvm", "api")
+	r := m.Recorder(run)
+	r.stepStarted([]string{"pvcreate", "-y", "/dev/loop0"})
+	r.recordOutput("stdout", `  Physical volume "/dev/loop0" successfully created.`)
+	r.recordStep(StepResult{Argv: []string{"pvcreate", "-y", "/dev/loop0"}})
+	r.stepStarted([]string{"vgcreate", "testvg", "/dev/loop0"})
+	r.recordOutput("stderr", "  Device /dev/loop0 excluded by a filter.")
+	r.recordStep(StepResult{Argv: []string{"vgcreate", "testvg", "/dev/loop0"}, ExitCode: 5})
+	m.Finish(run, errors.New("command failed: vgcreate"))
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantCode    int
+		wantTypes   []string
+		wantFirst   int
+		success     bool
+	}{
+		{
+			name:      "success: replays every event of a finished run",
+			wantCode:  http.StatusOK,
+			wantTypes: []string{"step-started", "step-output", "step-finished", "step-started", "step-output", "step-finished", "run-finished"},
+			wantFirst: 1,
+			success:   true,
+		},
+		{
+			name:        "success: resumes after Last-Event-ID",
+			lastEventID: "5",
+			wantCode:    http.StatusOK,
+			wantTypes:   []string{"step-finished", "run-finished"},
+			wantFirst:   6,
+			success:     true,
+		},
+		{
+			name:        "failure: invalid Last-Event-ID",
+			lastEventID: "five",
+			wantCode:    http.StatusBadRequest,
+			success:     false,
+		},
+	}
+
+	mux := http.NewServeMux()
+	newRunAPI(m, "").Register(mux)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/runs/"+run.ID+"/events", nil)
+			if tt.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			events := readSSE(t, rec.Body.String())
+			if !reflect.DeepEqual(eventTypes(events), tt.wantTypes) {
+				t.Fatalf("events = %v, want %v", eventTypes(events), tt.wantTypes)
+			}
+			if events[0].Seq != tt.wantFirst || !strings.Contains(rec.Body.String(), "id: 7\nevent: run-finished\n") {
+				t.Errorf("stream = %q, want events from %d up to id 7", rec.Body.String(), tt.wantFirst)
+			}
+			if last := events[len(events)-1]; last.Status != RunFailed || last.Error != "command failed: vgcreate" {
+				t.Errorf("run-finished = %+v", last)
+			}
+		})
+	}
+}
+
+func TestStreamEvents_Live(t *testing.T) {
+	m := newRunManager()
+	run, _ := m.Start("disk", "api")
+	r := m.Recorder(run)
+	r.stepStarted([]string{"mkdir", "-p", "/home/test/file_systems_test"})
+	mux := http.NewServeMux()
+	newRunAPI(m, "").Register(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/runs/" + run.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	next := func() RunEvent {
+		t.Helper()
+		for lines.Scan() {
+			if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+				var ev RunEvent
+				if err := json.Unmarshal([]byte(data), &ev); err != nil {
+					t.Fatal(err)
+				}
+				return ev
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return RunEvent{}
+	}
+
+	if ev := next(); ev.Type != EventStepStarted || ev.Step != 1 {
+		t.Errorf("replayed event = %+v, want step-started of step 1", ev)
+	}
+	r.recordOutput("stdout", "created")
+	if ev := next(); ev.Type != EventStepOutput || ev.Line != "created" || ev.Stream != "stdout" {
+		t.Errorf("live event = %+v, want the output line", ev)
+	}
+	r.recordStep(StepResult{Argv: []string{"mkdir"}})
+	m.Finish(run, nil)
+	if ev := next(); ev.Type != EventStepFinished {
+		t.Errorf("event = %+v, want step-finished", ev)
+	}
+	if ev := next(); ev.Type != EventRunFinished || ev.Status != RunSucceeded {
+		t.Errorf("event = %+v, want run-finished succeeded", ev)
+	}
+	if lines.Scan() && lines.Text() != "" {
+		t.Errorf("stream continued after run-finished: %q", lines.Text())
+	}
+}
+
+// ===================== event limits =====================
+func TestRunManager_BoundsOutputEvents(t *testing.T) {
+	m := newRunManager()
+	run, _ := m.Start("lvm", "api")
+	r := m.Recorder(run)
+	r.stepStarted([]string{"mkfs.ext4", "-F", "/dev/mapper/testvg-testlv1"})
+	for i := 0; i < maxOutputEvents+50; i++ {
+		r.recordOutput("stdout", "Writing inode tables")
+	}
+	r.recordStep(StepResult{Argv: []string{"mkfs.ext4", "-F", "/dev/mapper/testvg-testlv1"}})
+	m.Finish(run, nil)
+
+	events, _, _, _ := m.Events(run.ID, 0)
+	counts := map[string]int{}
+	for _, ev := range events {
+		counts[ev.Type]++
+	}
+	if counts[EventStepOutput] != maxOutputEvents || counts[EventOutputTruncated] != 1 || len(events) != maxOutputEvents+4 {
+		t.Fatalf("event counts = %v, want %d output events and one output-truncated", counts, maxOutputEvents)
+	}
+
+	// The next run drops the output of this one but keeps its Seq numbers.
+	next, _ := m.Start("disk", "schedule")
+	defer m.Finish(next, nil)
+	events, _, _, _ = m.Events(run.ID, 0)
+	if got, want := eventTypes(events), []string{EventStepStarted, EventStepFinished, EventRunFinished}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("events after the next run started = %v, want %v", got, want)
+	}
+	if events[1].Seq != maxOutputEvents+3 {
+		t.Errorf("step-finished Seq = %d, want %d", events[1].Seq, maxOutputEvents+3)
+	}
+	if resumed, _, _, _ := m.Events(run.ID, 5); !reflect.DeepEqual(eventTypes(resumed), []string{EventStepFinished, EventRunFinished}) {
+		t.Errorf("events after Seq 5 = %v, want the step and run finished events", eventTypes(resumed))
+	}
+}
+
+// ===================== runArgv streaming =====================
+func TestRunArgv_StreamsLines(t *testing.T) {
+	m := newRunManager()
+	run, _ := m.Start("disk", "schedule")
+	defer m.Finish(run, nil)
+
+	err := runArgv([]string{"sh", "-c", `echo one; echo two >&2; printf 'no newline'; exit 3`}, m.Recorder(run))
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || exitCode(err) != 3 {
+		t.Fatalf("runArgv() error = %v, want exit status 3", err)
+	}
+	for _, line := range []string{"one\n", "two\n", "no newline"} {
+		if !strings.Contains(cmdErr.Output, line) {
+			t.Errorf("Output = %q, want it to contain %q", cmdErr.Output, line)
+		}
+	}
+
+	events, _, _, _ := m.Events(run.ID, 0)
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Stream+": "+ev.Line)
+	}
+	sort.Strings(got) // stdout and stderr are read concurrently
+	want := []string{"stderr: two", "stdout: no newline", "stdout: one"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("output events = %q, want %q", got, want)
+	}
+}
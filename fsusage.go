@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// fsUsageWarnPercent is the space or inode usage at which a filesystem is
+// flagged as filling up.
+const fsUsageWarnPercent = 90
+
+// pseudoFSTypes are filesystems without backing storage; statfs on them
+// reports zeros or kernel-internal numbers that aren't useful to report.
+var pseudoFSTypes = map[string]bool{
+	"autofs": true, "binfmt_misc": true, "bpf": true, "cgroup": true, "cgroup2": true,
+	"configfs": true, "debugfs": true, "devpts": true, "devtmpfs": true, "efivarfs": true,
+	"fusectl": true, "hugetlbfs": true, "mqueue": true, "nsfs": true, "proc": true,
+	"pstore": true, "rpc_pipefs": true, "securityfs": true, "selinuxfs": true,
+	"sysfs": true, "tracefs": true,
+}
+
+// FSUsage is the space and inode usage of one mounted filesystem.
+type FSUsage struct {
+	MountPoint string `json:"mount_point"`
+	Source     string `json:"source"`
+	FSType     string `json:"fs_type"`
+	SizeBytes  uint64 `json:"size_bytes"`
+	UsedBytes  uint64 `json:"used_bytes"`
+	AvailBytes uint64 `json:"avail_bytes"`
+	Inodes     uint64 `json:"inodes"`
+	InodesUsed uint64 `json:"inodes_used"`
+	InodesFree uint64 `json:"inodes_free"`
+}
+
+// UsedPercent matches df: used / (used + available), since the blocks
+// reserved for root are not available to us.
+func (u FSUsage) UsedPercent() float64 {
+	if u.UsedBytes+u.AvailBytes == 0 {
+		return 0
+	}
+	return float64(u.UsedBytes) / float64(u.UsedBytes+u.AvailBytes) * 100
+}
+
+// InodesUsedPercent is 0 on filesystems without a fixed inode count (btrfs).
+func (u FSUsage) InodesUsedPercent() float64 {
+	if u.Inodes == 0 {
+		return 0
+	}
+	return float64(u.InodesUsed) / float64(u.Inodes) * 100
+}
+
+func fsUsageFromStats(s FSStats) (size, used, avail uint64) {
+	return s.Blocks * s.BlockSize, (s.Blocks - s.Bfree) * s.BlockSize, s.Bavail * s.BlockSize
+}
+
+// realMounts drops pseudo filesystems and keeps only the topmost mount of
+// each mount point (later lines of mountinfo are mounted over earlier ones).
+func realMounts(mounts []MountInfo) []MountInfo {
+	last := make(map[string]int)
+	for i, m := range mounts {
+		last[m.MountPoint] = i
+	}
+	var out []MountInfo
+	for i, m := range mounts {
+		if pseudoFSTypes[m.FSType] || last[m.MountPoint] != i {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// readFSUsage calls Statfs for every real mount. Mounts we can't stat (e.g.
+// permission denied inside a container) are reported and skipped.
+func readFSUsage(mounts []MountInfo) []FSUsage {
+	var usage []FSUsage
+	for _, m := range realMounts(mounts) {
+		s, err := Statfs(m.MountPoint)
+		if err != nil {
+			fmt.Printf("statfs %s failed: %v\n", m.MountPoint, err)
+			continue
+		}
+		if s.Blocks == 0 {
+			continue
+		}
+		size, used, avail := fsUsageFromStats(s)
+		usage = append(usage, FSUsage{
+			MountPoint: m.MountPoint,
+			Source:     m.Source,
+			FSType:     m.FSType,
+			SizeBytes:  size,
+			UsedBytes:  used,
+			AvailBytes: avail,
+			Inodes:     s.Files,
+			InodesUsed: s.Files - s.Ffree,
+			InodesFree: s.Ffree,
+		})
+	}
+	return usage
+}
+
+// formatFSUsage renders a filesystem as one line of the machine info output,
+// with a warning when space or inodes are above fsUsageWarnPercent.
+func formatFSUsage(u FSUsage) string {
+	line := fmt.Sprintf("%s %s %s size %s used %s (%.1f%%) avail %s",
+		u.MountPoint, u.FSType, u.Source, formatBytes(u.SizeBytes), formatBytes(u.UsedBytes), u.UsedPercent(), formatBytes(u.AvailBytes))
+	if u.Inodes > 0 {
+		line += fmt.Sprintf(" inodes %d/%d (%.1f%%)", u.InodesUsed, u.Inodes, u.InodesUsedPercent())
+	}
+	if u.UsedPercent() >= fsUsageWarnPercent || u.InodesUsedPercent() >= fsUsageWarnPercent {
+		line += " WARNING: filling up"
+	}
+	return line
+}
+
+// readFilesystemUsage reports usage of all real mounts in /proc/self/mountinfo.
+func readFilesystemUsage() string {
+	mounts, err := readMountInfo()
+	if err != nil {
+		return "Reading mountinfo failed: " + err.Error()
+	}
+	usage := readFSUsage(mounts)
+	if len(usage) == 0 {
+		return "No filesystems found"
+	}
+	lines := make([]string, 0, len(usage))
+	for _, u := range usage {
+		lines = append(lines, formatFSUsage(u))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseSize parses a fallocate-style size such as "100M" or "1GiB" into
+// bytes. Only binary multiples are accepted, not fallocate's decimal "MB",
+// and sizes must fit in an int64.
+func parseSize(s string) (uint64, error) {
+	num := strings.TrimSuffix(strings.TrimSpace(s), "iB")
+	mult := uint64(1)
+	if n := len(num); n > 0 {
+		if i := strings.IndexByte("KMGT", num[n-1]); i >= 0 {
+			mult = uint64(1) << (10 * (i + 1))
+			num = num[:n-1]
+		}
+	}
+	v, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if v > math.MaxInt64/mult {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return v * mult, nil
+}
+
+// checkHeadroom returns an error if the filesystem holding dir has less than
+// need bytes available. dir need not exist yet; its nearest existing parent
+// is checked instead.
+func checkHeadroom(dir string, need uint64) error {
+	path := filepath.Clean(dir)
+	for !fileExists(path) && path != filepath.Dir(path) {
+		path = filepath.Dir(path)
+	}
+	s, err := Statfs(path)
+	if err != nil {
+		return fmt.Errorf("checking free space on %s: %w", path, err)
+	}
+	if _, _, avail := fsUsageFromStats(s); avail < need {
+		return fmt.Errorf("not enough free space on %s: %s available, %s needed", path, formatBytes(avail), formatBytes(need))
+	}
+	return nil
+}
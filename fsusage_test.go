@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================== parseSize =====================
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    uint64
+		wantErr bool
+		success bool
+	}{
+		{name: "success: megabytes", input: "100M", want: 100 << 20, success: true},
+		{name: "success: gibibytes", input: "1GiB", want: 1 << 30, success: true},
+		{name: "success: plain bytes", input: "4096", want: 4096, success: true},
+		{name: "failure: decimal suffix", input: "100MB", wantErr: true, success: false},
+		{name: "failure: unknown suffix", input: "10X", wantErr: true, success: false},
+		{name: "success: largest size", input: "8388607T", want: 8388607 << 40, success: true},
+		{name: "failure: empty", input: "", wantErr: true, success: false},
+		{name: "failure: overflows with suffix", input: "99999999999T", wantErr: true, success: false},
+		{name: "failure: just past int64", input: "8388608T", wantErr: true, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== realMounts =====================
+func TestRealMounts(t *testing.T) {
+	data, err := os.ReadFile("testdata/proc/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts := append(parseMountInfo(data),
+		MountInfo{MountPoint: "/sys/fs/cgroup", FSType: "cgroup2"},
+		MountInfo{MountPoint: "/mnt/lvm1", FSType: "ext4", Source: "/dev/sdb1"}, // mounted over testlv1
+	)
+
+	var got []string
+	for _, m := range realMounts(mounts) {
+		got = append(got, m.MountPoint+"="+m.Source)
+	}
+	want := []string{"/=/dev/sda1", "/run=tmpfs", "/srv/my data=/dev/md0", "/var/lib/exports=/dev/md0", "/mnt/lvm1=/dev/sdb1"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("realMounts() = %v, want %v", got, want)
+	}
+}
+
+// ===================== readFilesystemUsage =====================
+func TestReadFilesystemUsage(t *testing.T) {
+	oldReadFile, oldStatfs := ReadFile, Statfs
+	defer func() { ReadFile, Statfs = oldReadFile, oldStatfs }()
+
+	tests := []struct {
+		name        string
+		mountErr    error
+		stats       map[string]FSStats
+		wantContain []string
+		wantMissing []string
+		success     bool
+	}{
+		{
+			name: "success: usage and warnings",
+			stats: map[string]FSStats{
+				"/":                {BlockSize: 4096, Blocks: 262144, Bfree: 131072, Bavail: 118784, Files: 65536, Ffree: 60000},
+				"/run":             {BlockSize: 4096, Blocks: 1024, Bfree: 50, Bavail: 50},
+				"/mnt/lvm1":        {BlockSize: 1024, Blocks: 102400, Bfree: 100000, Bavail: 100000, Files: 100, Ffree: 5},
+				"/srv/my data":     {BlockSize: 4096},
+				"/var/lib/exports": {BlockSize: 4096},
+			},
+			wantContain: []string{
+				"/ ext4 /dev/sda1 size 1.00 GiB used 512.00 MiB (52.5%) avail 464.00 MiB inodes 5536/65536 (8.4%)\n",
+				"/run tmpfs tmpfs size 4.00 MiB used 3.80 MiB (95.1%) avail 200.00 KiB WARNING: filling up",
+				"/mnt/lvm1 ext4 /dev/mapper/testvg-testlv1 size 100.00 MiB used 2.34 MiB (2.3%) avail 97.66 MiB inodes 95/100 (95.0%) WARNING: filling up",
+			},
+			wantMissing: []string{"/proc", "/sys", "/dev ", "/srv/my data"},
+			success:     true,
+		},
+		{
+			name:        "failure: statfs denied skips mount",
+			stats:       map[string]FSStats{"/": {BlockSize: 4096, Blocks: 10, Bfree: 5, Bavail: 5}},
+			wantContain: []string{"/ ext4 /dev/sda1"},
+			wantMissing: []string{"/run"},
+			success:     false,
+		},
+		{
+			name:        "failure: mountinfo unreadable",
+			mountErr:    errors.New("permission denied"),
+			wantContain: []string{"Reading mountinfo failed: permission denied"},
+			success:     false,
+		},
+		{
+			name:        "failure: nothing statable",
+			stats:       map[string]FSStats{},
+			wantContain: []string{"No filesystems found"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if tt.mountErr != nil {
+					return nil, tt.mountErr
+				}
+				return os.ReadFile("testdata/proc/mountinfo")
+			}
+			Statfs = func(path string) (FSStats, error) {
+				s, ok := tt.stats[path]
+				if !ok {
+					return FSStats{}, errors.New("permission denied")
+				}
+				return s, nil
+			}
+			got := readFilesystemUsage()
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("readFilesystemUsage() = %q, want to contain %q", got, want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(got, missing) {
+					t.Errorf("readFilesystemUsage() = %q, want no %q", got, missing)
+				}
+			}
+		})
+	}
+}
+
+// ===================== checkHeadroom =====================
+func TestCheckHeadroom(t *testing.T) {
+	oldStatfs := Statfs
+	defer func() { Statfs = oldStatfs }()
+
+	tests := []struct {
+		name     string
+		dir      string
+		avail    uint64
+		statErr  error
+		wantPath string
+		wantErr  string
+		success  bool
+	}{
+		{name: "success: enough space", dir: "testdata", avail: 200 << 20, wantPath: "testdata", success: true},
+		{name: "success: missing dir checks nearest parent", dir: "testdata/not/yet/created", avail: 200 << 20, wantPath: "testdata", success: true},
+		{name: "failure: not enough space", dir: "testdata", avail: 50 << 20, wantPath: "testdata", wantErr: "not enough free space on testdata: 50.00 MiB available, 100.00 MiB needed", success: false},
+		{name: "failure: statfs error", dir: "testdata", statErr: errors.New("permission denied"), wantPath: "testdata", wantErr: "checking free space on testdata: permission denied", success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var statted string
+			Statfs = func(path string) (FSStats, error) {
+				statted = path
+				return FSStats{BlockSize: 1, Blocks: tt.avail, Bavail: tt.avail}, tt.statErr
+			}
+			err := checkHeadroom(tt.dir, 100<<20)
+			if statted != tt.wantPath {
+				t.Errorf("statfs called on %q, want %q", statted, tt.wantPath)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkHeadroom() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("checkHeadroom() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckTestHeadroom_NsenterStatsTheNode(t *testing.T) {
+	oldStatfs, oldElevation := Statfs, elevation
+	defer func() { Statfs, elevation = oldStatfs, oldElevation }()
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "home/test"), 0o755)
+	t.Setenv("HOME", "/home/test")
+	elevation = elevations["nsenter"]
+	elevation.Root = root
+	var statted string
+	Statfs = func(path string) (FSStats, error) {
+		statted = path
+		return FSStats{BlockSize: 1, Blocks: 1 << 30, Bavail: 1 << 30}, nil
+	}
+
+	if err := checkTestHeadroom(); err != nil {
+		t.Fatalf("checkTestHeadroom() = %v", err)
+	}
+	if want := filepath.Join(root, "home/test"); statted != want {
+		t.Errorf("statfs called on %q, want the node's home directory %q", statted, want)
+	}
+}
+
+func TestCheckTestHeadroom_FullDiskIsTransient(t *testing.T) {
+	oldStatfs := Statfs
+	defer func() { Statfs = oldStatfs }()
+	Statfs = func(path string) (FSStats, error) {
+		return FSStats{BlockSize: 1, Blocks: 1 << 30, Bavail: 1 << 20}, nil
+	}
+
+	err := checkTestHeadroom()
+	var skip *SkipError
+	if !errors.As(err, &skip) || classifyError(err) != Transient {
+		t.Errorf("checkTestHeadroom() = %v classified %s, want a transient skip so the breaker retries once space is freed", err, classifyError(err))
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// procedureHealth is what readiness needs to know about a procedure.
+type procedureHealth struct {
+	Preflight   *PreflightReport
+	LastRun     time.Time
+	LastErr     string
+	LastSuccess time.Time
+}
+
+// procedureHealths is keyed by lower-case procedure name ("disk", "lvm").
+var (
+	healthMu         sync.Mutex
+	procedureHealths = map[string]*procedureHealth{}
+)
+
+func updateProcedureHealth(procedure string, f func(*procedureHealth)) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	key := strings.ToLower(procedure)
+	h, ok := procedureHealths[key]
+	if !ok {
+		h = &procedureHealth{}
+		procedureHealths[key] = h
+	}
+	f(h)
+}
+
+func getProcedureHealth(procedure string) procedureHealth {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	if h, ok := procedureHealths[strings.ToLower(procedure)]; ok {
+		return *h
+	}
+	return procedureHealth{}
+}
+
+// recordPreflight remembers the latest preflight report of a procedure.
+func recordPreflight(procedure string, r PreflightReport) {
+	updateProcedureHealth(procedure, func(h *procedureHealth) { h.Preflight = &r })
+}
+
+// recordProcedureRun remembers the outcome of a procedure run ending at at.
+func recordProcedureRun(procedure string, at time.Time, err error) {
+	updateProcedureHealth(procedure, func(h *procedureHealth) {
+		h.LastRun, h.LastErr = at, ""
+		if err != nil {
+			h.LastErr, _, _ = strings.Cut(err.Error(), "\n")
+			return
+		}
+		h.LastSuccess = at
+	})
+}
+
+// HealthResponse is the JSON body of /healthz and /readyz.
+type HealthResponse struct {
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons"`
+}
+
+// HealthChecker answers liveness and readiness for the selected procedure.
+// Live means no job has been running past Deadline; ready means the last
+// preflight passed and the procedure succeeded within ReadyWindow.
+type HealthChecker struct {
+	clock       Clock
+	scheduler   *Scheduler
+	procedure   string
+	Deadline    time.Duration
+	ReadyWindow time.Duration
+}
+
+func newHealthChecker(clock Clock, scheduler *Scheduler, procedure string, deadline, readyWindow time.Duration) *HealthChecker {
+	return &HealthChecker{clock: clock, scheduler: scheduler, procedure: procedure, Deadline: deadline, ReadyWindow: readyWindow}
+}
+
+// Live reports whether the main loop is making progress.
+func (h *HealthChecker) Live() (bool, []string) {
+	now := h.clock.Now()
+	stats := h.scheduler.Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var stuck []string
+	for _, name := range names {
+		st := stats[name]
+		if st.Running && now.Sub(st.LastStart) > h.Deadline {
+			stuck = append(stuck, fmt.Sprintf("job %s has been running for %s, past the %s deadline",
+				name, now.Sub(st.LastStart).Round(time.Second), h.Deadline))
+		}
+	}
+	if len(stuck) > 0 {
+		return false, stuck
+	}
+	return true, []string{fmt.Sprintf("no job running past the %s deadline", h.Deadline)}
+}
+
+// Ready reports whether the selected procedure works on this node.
+func (h *HealthChecker) Ready() (bool, []string) {
+	now := h.clock.Now()
+	ph := getProcedureHealth(h.procedure)
+	ready := true
+	var reasons []string
+
+	switch {
+	case ph.Preflight == nil:
+		ready = false
+		reasons = append(reasons, fmt.Sprintf("preflight for %s procedure has not run yet", h.procedure))
+	case ph.Preflight.Err() != nil:
+		ready = false
+		reasons = append(reasons, ph.Preflight.Err().Error())
+	default:
+		reasons = append(reasons, fmt.Sprintf("preflight passed all %d checks", len(ph.Preflight.Checks)))
+	}
+
+	switch {
+	case ph.LastSuccess.IsZero():
+		ready = false
+		reasons = append(reasons, fmt.Sprintf("%s procedure has not succeeded yet", h.procedure))
+	case now.Sub(ph.LastSuccess) > h.ReadyWindow:
+		ready = false
+		reasons = append(reasons, fmt.Sprintf("%s procedure last succeeded at %s, more than %s ago",
+			h.procedure, ph.LastSuccess.Format(time.RFC3339), h.ReadyWindow))
+	default:
+		reasons = append(reasons, fmt.Sprintf("%s procedure succeeded at %s", h.procedure, ph.LastSuccess.Format(time.RFC3339)))
+	}
+	if ph.LastErr != "" {
+		reasons = append(reasons, fmt.Sprintf("last run at %s failed: %s", ph.LastRun.Format(time.RFC3339), ph.LastErr))
+	}
+	return ready, reasons
+}
+
+// Register adds GET /healthz and GET /readyz to mux.
+func (h *HealthChecker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		ok, reasons := h.Live()
+		writeHealth(w, ok, "ok", "unhealthy", reasons)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ok, reasons := h.Ready()
+		writeHealth(w, ok, "ready", "not ready", reasons)
+	})
+}
+
+func writeHealth(w http.ResponseWriter, ok bool, okStatus, failStatus string, reasons []string) {
+	resp, code := HealthResponse{Status: okStatus, Reasons: reasons}, http.StatusOK
+	if !ok {
+		resp.Status, code = failStatus, http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, resp)
+}
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
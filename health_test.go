@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetProcedureHealths clears recorded procedure state for the test.
+func resetProcedureHealths(t *testing.T) {
+	t.Helper()
+	healthMu.Lock()
+	procedureHealths = map[string]*procedureHealth{}
+	healthMu.Unlock()
+	t.Cleanup(func() {
+		healthMu.Lock()
+		procedureHealths = map[string]*procedureHealth{}
+		healthMu.Unlock()
+	})
+}
+
+func getHealth(t *testing.T, h *HealthChecker, path string) (int, HealthResponse) {
+	t.Helper()
+	mux := http.NewServeMux()
+	h.Register(mux)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var resp HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("GET %s: invalid JSON %q: %v", path, rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+// ===================== /healthz =====================
+func TestHealthz(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		elapsed    time.Duration // time since the procedure job started
+		running    bool
+		wantCode   int
+		wantReason string
+		success    bool
+	}{
+		{
+			name:       "success: job running within the deadline",
+			elapsed:    9 * time.Minute,
+			running:    true,
+			wantCode:   http.StatusOK,
+			wantReason: "no job running past the 10m0s deadline",
+			success:    true,
+		},
+		{
+			name:       "success: idle long after the last run",
+			elapsed:    time.Hour,
+			running:    false,
+			wantCode:   http.StatusOK,
+			wantReason: "no job running past the 10m0s deadline",
+			success:    true,
+		},
+		{
+			name:       "failure: job stuck past the deadline",
+			elapsed:    12 * time.Minute,
+			running:    true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantReason: "job procedure has been running for 12m0s, past the 10m0s deadline",
+			success:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: start.Add(tt.elapsed)}
+			s := newScheduler(clock)
+			s.update("cpu", func(st *JobStats) { st.LastStart, st.LastEnd = start, start })
+			s.update("procedure", func(st *JobStats) { st.Running, st.LastStart = tt.running, start })
+
+			code, resp := getHealth(t, newHealthChecker(clock, s, "lvm", 10*time.Minute, 15*time.Minute), "/healthz")
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+			if len(resp.Reasons) != 1 || resp.Reasons[0] != tt.wantReason {
+				t.Errorf("reasons = %q, want [%q]", resp.Reasons, tt.wantReason)
+			}
+		})
+	}
+}
+
+// ===================== /readyz =====================
+func TestReadyz(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	passed := PreflightReport{Procedure: "LVM", Checks: []PreflightCheck{{Name: "euid", OK: true}, {Name: "binary pvcreate", OK: true}}}
+	failed := PreflightReport{Procedure: "LVM", Checks: []PreflightCheck{{Name: "euid", OK: true}, {Name: "binary pvcreate", OK: false}}}
+	tests := []struct {
+		name        string
+		record      func()
+		wantCode    int
+		wantStatus  string
+		wantReasons []string
+		success     bool
+	}{
+		{
+			name: "success: preflight passed and recent success",
+			record: func() {
+				recordPreflight("LVM", passed)
+				recordProcedureRun("LVM", now.Add(-time.Minute), nil)
+			},
+			wantCode:    http.StatusOK,
+			wantStatus:  "ready",
+			wantReasons: []string{"preflight passed all 2 checks", "lvm procedure succeeded at 2024-05-01T11:59:00Z"},
+			success:     true,
+		},
+		{
+			name:        "failure: nothing has run yet",
+			record:      func() {},
+			wantCode:    http.StatusServiceUnavailable,
+			wantStatus:  "not ready",
+			wantReasons: []string{"preflight for lvm procedure has not run yet", "lvm procedure has not succeeded yet"},
+			success:     false,
+		},
+		{
+			name: "failure: preflight failed",
+			record: func() {
+				recordPreflight("LVM", failed)
+				recordProcedureRun("LVM", now, &SkipError{Reason: "preflight failed: binary pvcreate"})
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "not ready",
+			wantReasons: []string{
+				"skipped: preflight failed: binary pvcreate",
+				"lvm procedure has not succeeded yet",
+				"last run at 2024-05-01T12:00:00Z failed: skipped: preflight failed: binary pvcreate",
+			},
+			success: false,
+		},
+		{
+			name: "failure: last success is outside the window",
+			record: func() {
+				recordPreflight("LVM", passed)
+				recordProcedureRun("LVM", now.Add(-time.Hour), nil)
+				recordProcedureRun("LVM", now.Add(-time.Minute), errors.New("command umount failed: exit status 32\ntarget is busy"))
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: "not ready",
+			wantReasons: []string{
+				"preflight passed all 2 checks",
+				"lvm procedure last succeeded at 2024-05-01T11:00:00Z, more than 15m0s ago",
+				"last run at 2024-05-01T11:59:00Z failed: command umount failed: exit status 32",
+			},
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetProcedureHealths(t)
+			tt.record()
+			clock := &fakeClock{now: now}
+			code, resp := getHealth(t, newHealthChecker(clock, newScheduler(clock), "lvm", 10*time.Minute, 15*time.Minute), "/readyz")
+			if code != tt.wantCode || resp.Status != tt.wantStatus {
+				t.Errorf("got %d %q, want %d %q", code, resp.Status, tt.wantCode, tt.wantStatus)
+			}
+			if strings.Join(resp.Reasons, "\n") != strings.Join(tt.wantReasons, "\n") {
+				t.Errorf("reasons = %q, want %q", resp.Reasons, tt.wantReasons)
+			}
+		})
+	}
+}
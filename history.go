@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// History record kinds.
+const (
+	HistorySnapshot = "snapshot"
+	HistoryRun      = "run"
+)
+
+// HistoryRecord is one line of the history store: either the machine info
+// collected in one iteration (collector name to its output) or a finished
+// procedure run.
+type HistoryRecord struct {
+	Time    time.Time         `json:"time"`
+	Kind    string            `json:"kind"`
+	Machine map[string]string `json:"machine,omitempty"`
+	Run     *Run              `json:"run,omitempty"`
+}
+
+// HistoryQuery selects records. Zero fields match everything; Procedure and
+// Status only match runs. A positive Limit keeps only the newest Limit
+// matching records.
+type HistoryQuery struct {
+	Since     time.Time
+	Until     time.Time
+	Kind      string
+	Procedure string
+	Status    string
+	Limit     int
+}
+
+func (q HistoryQuery) match(rec HistoryRecord) bool {
+	switch {
+	case !q.Since.IsZero() && rec.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && rec.Time.After(q.Until):
+		return false
+	case q.Kind != "" && rec.Kind != q.Kind:
+		return false
+	}
+	if q.Procedure == "" && q.Status == "" {
+		return true
+	}
+	return rec.Run != nil &&
+		(q.Procedure == "" || rec.Run.Procedure == q.Procedure) &&
+		(q.Status == "" || rec.Run.Status == q.Status)
+}
+
+// HistoryLimits bound the history store. The newest segment is appended to
+// until it reaches SegmentSize or was created SegmentAge ago; segments last
+// written more than MaxAge ago are deleted, and so are the oldest ones while
+// all segments together exceed TotalSize.
+type HistoryLimits struct {
+	SegmentSize int64
+	SegmentAge  time.Duration
+	TotalSize   int64
+	MaxAge      time.Duration
+}
+
+// HistoryStore is an append-only JSON-lines store made of segment files
+// history-<unix nanoseconds>.jsonl in dir, kept within its limits. A record
+// torn by a crash is at worst one unparsable line: the next append
+// terminates it first, and readers skip and count it.
+type HistoryStore struct {
+	dir    string
+	limits HistoryLimits
+	clock  Clock
+	mu     sync.Mutex
+}
+
+func openHistoryStore(dir string, limits HistoryLimits, clock Clock) (*HistoryStore, error) {
+	if limits.SegmentSize <= 0 || limits.SegmentAge <= 0 || limits.TotalSize <= 0 || limits.MaxAge <= 0 {
+		return nil, fmt.Errorf("segment size, segment age, total size and max age must be positive")
+	}
+	if limits.TotalSize < limits.SegmentSize {
+		return nil, fmt.Errorf("total size %d is smaller than the segment size %d", limits.TotalSize, limits.SegmentSize)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &HistoryStore{dir: dir, limits: limits, clock: clock}, nil
+}
+
+// segments returns the segment paths, oldest first.
+func (s *HistoryStore) segments() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "history-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// segmentNanos returns the timestamp in a segment's name.
+func segmentNanos(path string) int64 {
+	var n int64
+	fmt.Sscanf(filepath.Base(path), "history-%d.jsonl", &n)
+	return n
+}
+
+// Append writes rec as one line, rotating and pruning segments first.
+func (s *HistoryStore) Append(rec HistoryRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := s.segments()
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now()
+	var active string
+	next := now.UnixNano()
+	if n := len(paths); n > 0 {
+		last := segmentNanos(paths[n-1])
+		if fi, err := os.Stat(paths[n-1]); err == nil && fi.Size() < s.limits.SegmentSize && now.Sub(time.Unix(0, last)) < s.limits.SegmentAge {
+			active, paths = paths[n-1], paths[:n-1]
+		} else if next <= last {
+			next = last + 1 // keep segment names increasing
+		}
+	}
+	if err := s.prune(paths, active, int64(len(line)), now); err != nil {
+		return err
+	}
+	if active == "" {
+		active = filepath.Join(s.dir, fmt.Sprintf("history-%019d.jsonl", next))
+	}
+
+	f, err := os.OpenFile(active, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...) // terminate a torn record
+		}
+	}
+	if _, err := f.Write(line); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// prune deletes the closed segments, oldest first, that are older than MaxAge
+// or would make the store exceed TotalSize once adding bytes to active.
+func (s *HistoryStore) prune(closed []string, active string, adding int64, now time.Time) error {
+	total := adding
+	if fi, err := os.Stat(active); err == nil {
+		total += fi.Size()
+	}
+	var kept []string
+	sizes := map[string]int64{}
+	for _, p := range closed {
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if now.Sub(fi.ModTime()) > s.limits.MaxAge {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, p)
+		sizes[p] = fi.Size()
+		total += fi.Size()
+	}
+	for _, p := range kept {
+		if total <= s.limits.TotalSize {
+			break
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		total -= sizes[p]
+	}
+	return nil
+}
+
+// Query returns the matching records, oldest first, and how many lines
+// couldn't be parsed. With a Limit only that many records are held at once.
+func (s *HistoryStore) Query(q HistoryQuery) ([]HistoryRecord, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := s.segments()
+	if err != nil {
+		return nil, 0, err
+	}
+	records, corrupt := []HistoryRecord{}, 0
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		br := bufio.NewReader(f)
+		for {
+			line, err := br.ReadBytes('\n')
+			if len(strings.TrimSpace(string(line))) > 0 {
+				var rec HistoryRecord
+				if json.Unmarshal(line, &rec) != nil || rec.Kind == "" {
+					corrupt++
+				} else if q.match(rec) {
+					records = append(records, rec)
+					if q.Limit > 0 && len(records) > q.Limit {
+						records = records[1:]
+					}
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+	}
+	return records, corrupt, nil
+}
+
+// history is the store records are written to; nil (the default in tests)
+// disables it.
+var history *HistoryStore
+
+// recordHistory appends rec to the history store, if any.
+func recordHistory(rec HistoryRecord) {
+	if history == nil {
+		return
+	}
+	if err := history.Append(rec); err != nil {
+		fmt.Println("Writing history record failed:", err)
+	}
+}
+
+// parseHistoryTime accepts RFC 3339 or a duration meaning that long before now.
+func parseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (want RFC 3339 or a duration such as 24h)", s)
+	}
+	return t, nil
+}
+
+// parseHistoryQuery builds a query from since, until, kind, procedure, status
+// and limit values.
+func parseHistoryQuery(get func(string) string, now time.Time) (HistoryQuery, error) {
+	q := HistoryQuery{Kind: get("kind"), Procedure: get("procedure"), Status: get("status")}
+	var err error
+	if q.Since, err = parseHistoryTime(get("since"), now); err != nil {
+		return q, fmt.Errorf("since: %w", err)
+	}
+	if q.Until, err = parseHistoryTime(get("until"), now); err != nil {
+		return q, fmt.Errorf("until: %w", err)
+	}
+	if q.Kind != "" && q.Kind != HistoryRun && q.Kind != HistorySnapshot {
+		return q, fmt.Errorf("unknown kind %q (want run or snapshot)", q.Kind)
+	}
+	if v := get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("limit: invalid count %q (want a non-negative integer)", v)
+		}
+	}
+	return q, nil
+}
+
+// historyResponse is the JSON body of GET /v1/history and history -format json.
+type historyResponse struct {
+	Records []HistoryRecord `json:"records"`
+	Corrupt int             `json:"corrupt_lines"`
+}
+
+// Register adds GET /v1/history?since=&until=&kind=&procedure=&status=&limit=
+// to mux.
+func (s *HistoryStore) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /v1/history", func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseHistoryQuery(r.URL.Query().Get, s.clock.Now())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		records, corrupt, err := s.Query(q)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, historyResponse{Records: records, Corrupt: corrupt})
+	})
+}
+
+// runDuration is how long a finished run took, or 0 for a running one.
+func runDuration(r *Run) time.Duration {
+	if r.Finished == nil {
+		return 0
+	}
+	return r.Finished.Sub(r.Started).Round(time.Millisecond)
+}
+
+// formatHistory renders records one per line.
+func formatHistory(records []HistoryRecord, corrupt int) string {
+	var b strings.Builder
+	for _, rec := range records {
+		ts := rec.Time.Format(time.RFC3339)
+		if rec.Run != nil {
+			r := rec.Run
+			fmt.Fprintf(&b, "%s  run       %-4s  %-9s  %s  %-8s  %2d steps  %s", ts, r.Procedure, r.Status, r.ID, r.Trigger, len(r.Steps), runDuration(r))
+			if r.Error != "" {
+				msg, _, _ := strings.Cut(r.Error, "\n")
+				fmt.Fprintf(&b, "  %s", msg)
+			}
+			b.WriteString("\n")
+			continue
+		}
+		names := make([]string, 0, len(rec.Machine))
+		for name := range rec.Machine {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "%s  snapshot  %s\n", ts, strings.Join(names, ", "))
+	}
+	fmt.Fprintf(&b, "%d record(s)", len(records))
+	if corrupt > 0 {
+		fmt.Fprintf(&b, ", %d unreadable line(s) skipped", corrupt)
+	}
+	return b.String()
+}
+
+// runHistoryCommand implements the history subcommand and returns the exit
+// code.
+func runHistoryCommand(args []string, w io.Writer) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(w)
+	dataDir := fs.String("data-dir", defaultDataDir, "Directory of the history store")
+	since := fs.String("since", "", "Only records at or after this time (RFC 3339, or a duration such as 24h meaning that long ago)")
+	until := fs.String("until", "", "Only records at or before this time (RFC 3339 or a duration)")
+	kind := fs.String("kind", "", "Only records of this kind: run or snapshot")
+	procedure := fs.String("procedure", "", "Only runs of this procedure: disk or lvm")
+	status := fs.String("status", "", "Only runs with this status: succeeded, failed or skipped")
+	limit := fs.Int("limit", 0, "Only the newest this many matching records (0 means all)")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	values := map[string]string{"since": *since, "until": *until, "kind": *kind, "procedure": *procedure, "status": *status, "limit": strconv.Itoa(*limit)}
+	q, err := parseHistoryQuery(func(k string) string { return values[k] }, time.Now())
+	if err != nil {
+		fmt.Fprintln(w, "Invalid query:", err)
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(w, "Invalid -format: unknown format %q (want text or json)\n", *format)
+		return 2
+	}
+	if _, err := os.Stat(*dataDir); err != nil {
+		fmt.Fprintln(w, "Reading history failed:", err)
+		return 1
+	}
+	store := &HistoryStore{dir: *dataDir}
+	records, corrupt, err := store.Query(q)
+	if err != nil {
+		fmt.Fprintln(w, "Reading history failed:", err)
+		return 1
+	}
+	if *format == "json" {
+		out, _ := json.MarshalIndent(historyResponse{Records: records, Corrupt: corrupt}, "", "  ")
+		fmt.Fprintln(w, string(out))
+		return 0
+	}
+	fmt.Fprintln(w, formatHistory(records, corrupt))
+	return 0
+}
+
+// defaultDataDir is where the pod manifests keep the history store, and so
+// where the history subcommand looks unless -data-dir says otherwise.
+const defaultDataDir = "/var/lib/linux-pod"
+
+// historyMaxSize parses a -history-max-size or -history-max-total value.
+func historyMaxSize(s string) (int64, error) {
+	n, err := parseSize(s)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return int64(n), nil
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHistory opens a store in a temporary directory; zero limits default
+// to a day and a GiB.
+func newTestHistory(t *testing.T, limits HistoryLimits, clock Clock) *HistoryStore {
+	t.Helper()
+	if limits.SegmentSize == 0 {
+		limits.SegmentSize = 1 << 20
+	}
+	if limits.SegmentAge == 0 {
+		limits.SegmentAge = 24 * time.Hour
+	}
+	if limits.TotalSize == 0 {
+		limits.TotalSize = 1 << 30
+	}
+	if limits.MaxAge == 0 {
+		limits.MaxAge = 24 * time.Hour
+	}
+	s, err := openHistoryStore(t.TempDir(), limits, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func historyRun(at time.Time, procedure, status string) HistoryRecord {
+	return HistoryRecord{Time: at, Kind: HistoryRun, Run: &Run{ID: procedure + "-" + status, Procedure: procedure, Trigger: "schedule", Status: status, Started: at.Add(-time.Minute), Finished: &at, Steps: []StepResult{}}}
+}
+
+// ===================== HistoryStore.Query =====================
+func TestHistoryQuery(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestHistory(t, HistoryLimits{}, &fakeClock{now: start})
+	for _, rec := range []HistoryRecord{
+		{Time: start, Kind: HistorySnapshot, Machine: map[string]string{"cpu": "CPU cores: 4\n"}},
+		historyRun(start.Add(time.Minute), "disk", RunSucceeded),
+		historyRun(start.Add(2*time.Minute), "lvm", RunSkipped),
+		historyRun(start.Add(3*time.Minute), "lvm", RunFailed),
+	} {
+		if err := s.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   HistoryQuery
+		wantIDs []string // run IDs, "snapshot" for snapshots
+		success bool
+	}{
+		{name: "success: everything in order", query: HistoryQuery{}, wantIDs: []string{"snapshot", "disk-succeeded", "lvm-skipped", "lvm-failed"}, success: true},
+		{name: "success: by procedure", query: HistoryQuery{Procedure: "lvm"}, wantIDs: []string{"lvm-skipped", "lvm-failed"}, success: true},
+		{name: "success: by status", query: HistoryQuery{Status: RunFailed}, wantIDs: []string{"lvm-failed"}, success: true},
+		{name: "success: by kind", query: HistoryQuery{Kind: HistorySnapshot}, wantIDs: []string{"snapshot"}, success: true},
+		{name: "success: time range is inclusive", query: HistoryQuery{Since: start.Add(time.Minute), Until: start.Add(2 * time.Minute)}, wantIDs: []string{"disk-succeeded", "lvm-skipped"}, success: true},
+		{name: "success: limit keeps the newest", query: HistoryQuery{Limit: 2}, wantIDs: []string{"lvm-skipped", "lvm-failed"}, success: true},
+		{name: "success: limit applies after filtering", query: HistoryQuery{Kind: HistoryRun, Procedure: "disk", Limit: 2}, wantIDs: []string{"disk-succeeded"}, success: true},
+		{name: "failure: nothing matches", query: HistoryQuery{Procedure: "disk", Status: RunFailed}, wantIDs: nil, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, corrupt, err := s.Query(tt.query)
+			if err != nil || corrupt != 0 {
+				t.Fatalf("Query() = %d corrupt, %v", corrupt, err)
+			}
+			var ids []string
+			for _, rec := range records {
+				if rec.Run != nil {
+					ids = append(ids, rec.Run.ID)
+				} else {
+					ids = append(ids, "snapshot")
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("records = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// ===================== HistoryStore.Append =====================
+func TestHistoryAppend_SurvivesTornWrite(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestHistory(t, HistoryLimits{}, &fakeClock{now: start})
+	if err := s.Append(historyRun(start, "lvm", RunSucceeded)); err != nil {
+		t.Fatal(err)
+	}
+	// A crash in the middle of the second record leaves half a line.
+	paths, _ := s.segments()
+	f, err := os.OpenFile(paths[0], os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2024-05-01T12:01:00Z","kind":"run","run":{"id":"to`)
+	f.Close()
+	if err := s.Append(historyRun(start.Add(2*time.Minute), "lvm", RunFailed)); err != nil {
+		t.Fatal(err)
+	}
+
+	records, corrupt, err := s.Query(HistoryQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Run.ID != "lvm-succeeded" || records[1].Run.ID != "lvm-failed" || corrupt != 1 {
+		t.Errorf("Query() = %d records, %d corrupt, want both whole records and 1 corrupt line", len(records), corrupt)
+	}
+}
+
+func TestHistoryAppend_RotatesBySizeAndAge(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	s := newTestHistory(t, HistoryLimits{SegmentSize: 200}, clock) // about one record per segment
+	for i := 0; i < 3; i++ {
+		if err := s.Append(historyRun(clock.now, "disk", RunSucceeded)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths, _ := s.segments()
+	if len(paths) != 3 {
+		t.Fatalf("segments = %d, want 3 after exceeding the size three times", len(paths))
+	}
+
+	// The first segment was last written two days ago.
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(paths[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = time.Now()
+	if err := s.Append(historyRun(clock.now, "disk", RunSucceeded)); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := s.segments()
+	if len(after) != 3 || after[0] != paths[1] {
+		t.Errorf("segments = %v, want the aged %s deleted and a new one added", after, filepath.Base(paths[0]))
+	}
+}
+
+func TestHistoryAppend_RotatesActiveSegmentByAge(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	s := newTestHistory(t, HistoryLimits{SegmentAge: time.Hour}, clock)
+	for _, d := range []time.Duration{0, 30 * time.Minute, time.Hour} {
+		clock.now = start.Add(d)
+		if err := s.Append(historyRun(clock.now, "disk", RunSucceeded)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths, _ := s.segments()
+	if len(paths) != 2 {
+		t.Errorf("segments = %d, want 2: the first one is rotated once it is an hour old", len(paths))
+	}
+}
+
+func TestHistoryAppend_CapsTotalSize(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	line, _ := json.Marshal(historyRun(start, "disk", RunSucceeded))
+	size := int64(len(line) + 1)
+	s := newTestHistory(t, HistoryLimits{SegmentSize: size, TotalSize: 3 * size}, clock) // one record per segment
+	for i := 0; i < 6; i++ {
+		clock.now = start.Add(time.Duration(i) * time.Minute)
+		if err := s.Append(historyRun(clock.now, "disk", RunSucceeded)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths, _ := s.segments()
+	records, _, _ := s.Query(HistoryQuery{})
+	if len(paths) != 3 || len(records) != 3 || !records[0].Time.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("%d segments, %d records, want the newest 3 of 6 records in 3 segments", len(paths), len(records))
+	}
+}
+
+func TestOpenHistoryStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  HistoryLimits
+		success bool
+	}{
+		{name: "success: total holds several segments", limits: HistoryLimits{SegmentSize: 10 << 20, SegmentAge: 24 * time.Hour, TotalSize: 100 << 20, MaxAge: 168 * time.Hour}, success: true},
+		{name: "failure: total smaller than a segment", limits: HistoryLimits{SegmentSize: 10 << 20, SegmentAge: 24 * time.Hour, TotalSize: 1 << 20, MaxAge: 168 * time.Hour}, success: false},
+		{name: "failure: zero segment age", limits: HistoryLimits{SegmentSize: 10 << 20, TotalSize: 100 << 20, MaxAge: 168 * time.Hour}, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openHistoryStore(t.TempDir(), tt.limits, realClock{}); (err == nil) != tt.success {
+				t.Errorf("openHistoryStore() error = %v, want success %v", err, tt.success)
+			}
+		})
+	}
+}
+
+func TestHistoryMaxSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int64
+		success bool
+	}{
+		{name: "success: megabytes", input: "10M", want: 10 << 20, success: true},
+		{name: "failure: zero", input: "0", success: false},
+		{name: "failure: overflow is rejected, not wrapped", input: "99999999999T", success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := historyMaxSize(tt.input)
+			if (err == nil) != tt.success || got != tt.want {
+				t.Errorf("historyMaxSize(%q) = %d, %v, want %d (success %v)", tt.input, got, err, tt.want, tt.success)
+			}
+		})
+	}
+}
+
+// ===================== history subcommand =====================
+func TestRunHistoryCommand(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestHistory(t, HistoryLimits{}, &fakeClock{now: start})
+	s.Append(HistoryRecord{Time: start, Kind: HistorySnapshot, Machine: map[string]string{"cpu": "", "memory": ""}})
+	failed := historyRun(start.Add(time.Minute), "lvm", RunFailed)
+	failed.Run.Error = "command failed: pvcreate -y /dev/loop7\nOutput:\n"
+	s.Append(failed)
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		want     []string
+		success  bool
+	}{
+		{
+			name:     "success: text",
+			args:     []string{"-data-dir", s.dir},
+			wantCode: 0,
+			want: []string{
+				"2024-05-01T12:00:00Z  snapshot  cpu, memory\n",
+				"2024-05-01T12:01:00Z  run       lvm   failed     lvm-failed  schedule   0 steps  1m0s  command failed: pvcreate -y /dev/loop7\n",
+				"2 record(s)",
+			},
+			success: true,
+		},
+		{
+			name:     "success: json filtered by status",
+			args:     []string{"-data-dir", s.dir, "-status", "failed", "-format", "json"},
+			wantCode: 0,
+			want:     []string{`"id": "lvm-failed"`, `"corrupt_lines": 0`},
+			success:  true,
+		},
+		{
+			name:     "success: limit",
+			args:     []string{"-data-dir", s.dir, "-limit", "1"},
+			wantCode: 0,
+			want:     []string{"lvm-failed", "1 record(s)"},
+			success:  true,
+		},
+		{
+			name:     "failure: negative limit",
+			args:     []string{"-data-dir", s.dir, "-limit", "-1"},
+			wantCode: 2,
+			want:     []string{`Invalid query: limit: invalid count "-1"`},
+			success:  false,
+		},
+		{
+			name:     "failure: invalid time",
+			args:     []string{"-data-dir", s.dir, "-since", "yesterday"},
+			wantCode: 2,
+			want:     []string{`Invalid query: since: invalid time "yesterday"`},
+			success:  false,
+		},
+		{
+			name:     "failure: missing data dir",
+			args:     []string{"-data-dir", filepath.Join(s.dir, "missing")},
+			wantCode: 1,
+			want:     []string{"Reading history failed:"},
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runHistoryCommand(tt.args, &out); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.wantCode, out.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output = %q, want to contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
+
+// ===================== GET /v1/history =====================
+func TestHistoryEndpoint(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestHistory(t, HistoryLimits{}, &fakeClock{now: now})
+	s.Append(historyRun(now.Add(-2*time.Hour), "disk", RunSucceeded))
+	s.Append(historyRun(now.Add(-time.Minute), "disk", RunFailed))
+	mux := http.NewServeMux()
+	s.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/history?since=1h&procedure=disk", nil))
+	var resp historyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d %q: %v", rec.Code, rec.Body.String(), err)
+	}
+	if len(resp.Records) != 1 || resp.Records[0].Run.Status != RunFailed {
+		t.Errorf("records = %+v, want only the failed run of the last hour", resp.Records)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/history?limit=1", nil))
+	resp = historyResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Records) != 1 || resp.Records[0].Run.Status != RunFailed {
+		t.Errorf("GET limit=1 = %d %q, want only the newest record", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/history?limit=all", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET limit=all = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/history?kind=raid", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `unknown kind \"raid\"`) {
+		t.Errorf("GET kind=raid = %d %q, want 400", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// hostPath resolves an absolute path under a host-root prefix, e.g. "/host"
+// for a hostPath mount of the node's "/" or "/proc/1/root" when the pod runs
+// with hostPID. An empty or "/" root returns path unchanged.
+func hostPath(root, path string) string {
+	if root == "" || root == "/" {
+		return path
+	}
+	return filepath.Join(root, path)
+}
+
+// DistroInfo separates the container image's distribution from the node's.
+// When no host root is configured both describe the same filesystem.
+type DistroInfo struct {
+	HostRoot      string    `json:"host_root,omitempty"`
+	Container     OSRelease `json:"container"`
+	ContainerErr  string    `json:"container_error,omitempty"`
+	Host          OSRelease `json:"host"`
+	HostErr       string    `json:"host_error,omitempty"`
+	KernelRelease string    `json:"kernel_release"`
+}
+
+// readDistroInfo reads os-release from "/" and from hostRoot, plus the
+// running kernel release. Containers share the node's kernel, so uname always
+// describes the host.
+func readDistroInfo(hostRoot string) DistroInfo {
+	info := DistroInfo{HostRoot: hostRoot}
+
+	container, err := readOSRelease("")
+	if err != nil {
+		info.ContainerErr = err.Error()
+	}
+	info.Container = container
+
+	if hostPath(hostRoot, "/") == "/" {
+		info.Host, info.HostErr = info.Container, info.ContainerErr
+	} else {
+		host, err := readOSRelease(hostRoot)
+		if err != nil {
+			info.HostErr = err.Error()
+		}
+		info.Host = host
+	}
+
+	u, err := Uname()
+	if err != nil {
+		info.KernelRelease = "Error calling uname: " + err.Error()
+	} else {
+		info.KernelRelease = u.Release
+	}
+	return info
+}
+
+// formatDistro renders one side of DistroInfo for the machine info output.
+func formatDistro(r OSRelease, errMsg string) string {
+	if errMsg != "" {
+		return "Error reading " + errMsg
+	}
+	return fmt.Sprintf("%s (ID=%s VERSION_ID=%s)", r.PrettyName, r.ID, r.VersionID)
+}
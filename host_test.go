@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ===================== hostPath =====================
+func TestHostPath(t *testing.T) {
+	tests := []struct {
+		name string
+		root string
+		path string
+		want string
+	}{
+		{"success: empty root", "", "/etc/os-release", "/etc/os-release"},
+		{"success: slash root", "/", "/etc/os-release", "/etc/os-release"},
+		{"success: hostPath mount", "/host", "/etc/os-release", "/host/etc/os-release"},
+		{"success: hostPID root", "/proc/1/root", "/usr/lib/os-release", "/proc/1/root/usr/lib/os-release"},
+		{"success: trailing slash on root", "/host/", "/etc/os-release", "/host/etc/os-release"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hostPath(tt.root, tt.path); got != tt.want {
+				t.Errorf("hostPath(%q, %q) = %q, want %q", tt.root, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== readDistroInfo =====================
+func TestReadDistroInfo(t *testing.T) {
+	oldReadFile, oldUname := ReadFile, Uname
+	defer func() { ReadFile, Uname = oldReadFile, oldUname }()
+
+	tests := []struct {
+		name          string
+		hostRoot      string
+		files         map[string]string
+		readErr       error
+		unameErr      error
+		wantContainer string
+		wantHost      string
+		wantKernel    string
+		wantErr       bool
+		success       bool
+	}{
+		{
+			name:          "success: no host root reports container for both",
+			hostRoot:      "",
+			files:         map[string]string{"/etc/os-release": `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"`},
+			wantContainer: "Debian GNU/Linux 12 (bookworm)",
+			wantHost:      "Debian GNU/Linux 12 (bookworm)",
+			wantKernel:    "6.1.0-18-amd64",
+			success:       true,
+		},
+		{
+			name:     "success: host root reports node distro separately",
+			hostRoot: "/host",
+			files: map[string]string{
+				"/etc/os-release":      `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"`,
+				"/host/etc/os-release": `PRETTY_NAME="Ubuntu 22.04.3 LTS"`,
+			},
+			wantContainer: "Debian GNU/Linux 12 (bookworm)",
+			wantHost:      "Ubuntu 22.04.3 LTS",
+			wantKernel:    "6.1.0-18-amd64",
+			success:       true,
+		},
+		{
+			name:     "success: host falls back to /usr/lib/os-release",
+			hostRoot: "/proc/1/root",
+			files: map[string]string{
+				"/etc/os-release":                 `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"`,
+				"/proc/1/root/usr/lib/os-release": `PRETTY_NAME="Flatcar Container Linux"`,
+			},
+			wantContainer: "Debian GNU/Linux 12 (bookworm)",
+			wantHost:      "Flatcar Container Linux",
+			wantKernel:    "6.1.0-18-amd64",
+			success:       true,
+		},
+		{
+			name:          "failure: file read error",
+			hostRoot:      "",
+			files:         map[string]string{},
+			readErr:       errors.New("permission denied"),
+			wantContainer: "Error reading /etc/os-release",
+			wantHost:      "Error reading /etc/os-release",
+			wantKernel:    "6.1.0-18-amd64",
+			wantErr:       true,
+			success:       false,
+		},
+		{
+			name:          "failure: uname fails",
+			hostRoot:      "",
+			files:         map[string]string{"/etc/os-release": `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"`},
+			unameErr:      errors.New("not supported"),
+			wantContainer: "Debian GNU/Linux 12 (bookworm)",
+			wantHost:      "Debian GNU/Linux 12 (bookworm)",
+			wantKernel:    "Error calling uname",
+			success:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if data, ok := tt.files[path]; ok {
+					return []byte(data), nil
+				}
+				if tt.readErr != nil {
+					return nil, tt.readErr
+				}
+				return nil, errors.New("no such file")
+			}
+			Uname = func() (UnameInfo, error) {
+				return UnameInfo{Release: "6.1.0-18-amd64"}, tt.unameErr
+			}
+
+			info := readDistroInfo(tt.hostRoot)
+			container := formatDistro(info.Container, info.ContainerErr)
+			host := formatDistro(info.Host, info.HostErr)
+			if !strings.Contains(container, tt.wantContainer) {
+				t.Errorf("container distro = %q, want to contain %q", container, tt.wantContainer)
+			}
+			if !strings.Contains(host, tt.wantHost) {
+				t.Errorf("host distro = %q, want to contain %q", host, tt.wantHost)
+			}
+			if !strings.Contains(info.KernelRelease, tt.wantKernel) {
+				t.Errorf("KernelRelease = %q, want to contain %q", info.KernelRelease, tt.wantKernel)
+			}
+			if tt.wantErr && info.ContainerErr == "" {
+				t.Error("ContainerErr is empty, want error")
+			}
+		})
+	}
+}
+
+// ===================== formatDistro =====================
+func TestFormatDistro(t *testing.T) {
+	r := OSRelease{ID: "ubuntu", VersionID: "22.04", PrettyName: "Ubuntu 22.04.3 LTS"}
+	if got, want := formatDistro(r, ""), "Ubuntu 22.04.3 LTS (ID=ubuntu VERSION_ID=22.04)"; got != want {
+		t.Errorf("formatDistro() = %q, want %q", got, want)
+	}
+	if got := formatDistro(r, "/etc/os-release: denied"); got != "Error reading /etc/os-release: denied" {
+		t.Errorf("formatDistro() with error = %q", got)
+	}
+}
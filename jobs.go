@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is one collector or procedure of the main loop. Collectors are the
+// cheap "=== Machine Info ===" sections; their output is buffered and written
+// in one piece so it doesn't interleave with a procedure running alongside.
+type Job struct {
+	Name      string
+	Collector bool
+	Run       func(w io.Writer)
+}
+
+// JobStats is what the scheduler records about a job.
+type JobStats struct {
+	Runs      int       `json:"runs"`
+	Missed    int       `json:"missed"`
+	Running   bool      `json:"running"`
+	LastStart time.Time `json:"last_start,omitempty"`
+	LastEnd   time.Time `json:"last_end,omitempty"`
+}
+
+// jobSchedules collects repeated -job-schedule name=spec flags.
+type jobSchedules map[string]string
+
+func (j jobSchedules) String() string {
+	parts := make([]string, 0, len(j))
+	for name, spec := range j {
+		parts = append(parts, name+"="+spec)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func (j jobSchedules) Set(value string) error {
+	name, spec, ok := strings.Cut(value, "=")
+	if !ok || name == "" || strings.TrimSpace(spec) == "" {
+		return fmt.Errorf("want name=spec, got %q", value)
+	}
+	j[name] = strings.TrimSpace(spec)
+	return nil
+}
+
+// parseJobSchedule turns a spec into a schedule based on base: a Go duration
+// replaces the interval, anything else must be a cron expression.
+func parseJobSchedule(spec string, base Schedule, now time.Time) (Schedule, error) {
+	s := base
+	if d, err := time.ParseDuration(spec); err == nil {
+		s.Interval = d
+		return s, s.Validate()
+	}
+	c, err := parseCron(spec)
+	if err != nil {
+		return s, err
+	}
+	if c.Next(now).IsZero() {
+		return s, fmt.Errorf("cron expression %q never matches", spec)
+	}
+	s.Cron = c
+	return s, nil
+}
+
+// jobUnit is a group of jobs sharing a schedule. They run one after another
+// in the order given, so jobs left on the default schedule keep the output
+// order of a single loop.
+type jobUnit struct {
+	Spec     string
+	Schedule Schedule
+	Jobs     []Job
+}
+
+// planJobUnits groups jobs by their -job-schedule spec; jobs without one use
+// base. Unknown job names are an error.
+func planJobUnits(jobs []Job, specs jobSchedules, base Schedule, now time.Time) ([]jobUnit, error) {
+	known := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		known[job.Name] = true
+	}
+	for name := range specs {
+		if !known[name] {
+			names := make([]string, len(jobs))
+			for i, job := range jobs {
+				names[i] = job.Name
+			}
+			return nil, fmt.Errorf("unknown job %q (want one of %s)", name, strings.Join(names, ", "))
+		}
+	}
+
+	var units []jobUnit
+	index := map[string]int{}
+	for _, job := range jobs {
+		spec := specs[job.Name]
+		i, ok := index[spec]
+		if !ok {
+			sched := base
+			if spec != "" {
+				var err error
+				if sched, err = parseJobSchedule(spec, base, now); err != nil {
+					return nil, fmt.Errorf("job %s: %w", job.Name, err)
+				}
+			}
+			i = len(units)
+			index[spec] = i
+			units = append(units, jobUnit{Spec: spec, Schedule: sched})
+		}
+		units[i].Jobs = append(units[i].Jobs, job)
+	}
+	return units, nil
+}
+
+// jobOutput is where jobs write (mockable in tests).
+var jobOutput io.Writer = os.Stdout
+
+// Scheduler runs job units independently and records per-job stats. A unit
+// runs its jobs sequentially, so two runs of the same job never overlap.
+type Scheduler struct {
+	clock Clock
+	mu    sync.Mutex
+	stats map[string]*JobStats
+}
+
+func newScheduler(clock Clock) *Scheduler {
+	return &Scheduler{clock: clock, stats: map[string]*JobStats{}}
+}
+
+// Stats returns a copy of the stats of every job that has been scheduled.
+func (s *Scheduler) Stats() map[string]JobStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]JobStats, len(s.stats))
+	for name, st := range s.stats {
+		out[name] = *st
+	}
+	return out
+}
+
+func (s *Scheduler) update(name string, f func(*JobStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[name]
+	if !ok {
+		st = &JobStats{}
+		s.stats[name] = st
+	}
+	f(st)
+}
+
+// Run starts every unit and returns once all of them have stopped (only
+// when MaxIterations is set).
+func (s *Scheduler) Run(units []jobUnit) {
+	var wg sync.WaitGroup
+	for _, u := range units {
+		u := u
+		for _, job := range u.Jobs {
+			s.update(job.Name, func(*JobStats) {})
+		}
+		u.Schedule.OnMissed = func(iteration, missed int) {
+			names := make([]string, len(u.Jobs))
+			for i, job := range u.Jobs {
+				names[i] = job.Name
+				s.update(job.Name, func(st *JobStats) { st.Missed += missed })
+			}
+			fmt.Fprintf(jobOutput, "Missed %d scheduled run(s) of %s while run %d was still going\n", missed, strings.Join(names, ", "), iteration)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runSchedule(u.Schedule, s.clock, func(int) { s.runUnit(u) })
+		}()
+	}
+	wg.Wait()
+}
+
+// runUnit runs the jobs of u once. The output of its collectors is also
+// recorded in the history store as one machine info snapshot.
+func (s *Scheduler) runUnit(u jobUnit) {
+	machine := map[string]string{}
+	for _, job := range u.Jobs {
+		var w io.Writer = jobOutput
+		var buf bytes.Buffer
+		if job.Collector {
+			w = &buf
+		}
+		s.update(job.Name, func(st *JobStats) { st.Running, st.LastStart = true, s.clock.Now() })
+		job.Run(w)
+		s.update(job.Name, func(st *JobStats) { st.Running, st.LastEnd, st.Runs = false, s.clock.Now(), st.Runs+1 })
+		if job.Collector {
+			out := buf.Bytes()
+			if len(machine) == 0 {
+				out = append([]byte("=== Machine Info ===\n"), out...)
+			}
+			jobOutput.Write(out)
+			machine[job.Name] = buf.String()
+		}
+	}
+	if len(machine) > 0 {
+		recordHistory(HistoryRecord{Time: s.clock.Now(), Kind: HistorySnapshot, Machine: machine})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testJobs(names ...string) []Job {
+	jobs := make([]Job, len(names))
+	for i, name := range names {
+		jobs[i] = Job{Name: name, Collector: name != "procedure", Run: func(w io.Writer) {}}
+	}
+	return jobs
+}
+
+// ===================== planJobUnits =====================
+func TestPlanJobUnits(t *testing.T) {
+	base := Schedule{Interval: 15 * time.Second, Mode: FixedDelay}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		specs     jobSchedules
+		wantUnits []string // job names of each unit, comma separated
+		wantErr   string
+		success   bool
+	}{
+		{
+			name:      "success: everything on the default schedule is one unit",
+			specs:     jobSchedules{},
+			wantUnits: []string{"cpu,network,procedure"},
+			success:   true,
+		},
+		{
+			name:      "success: procedure on cron, network on its own interval",
+			specs:     jobSchedules{"procedure": "0 */6 * * *", "network": "1m"},
+			wantUnits: []string{"cpu", "network", "procedure"},
+			success:   true,
+		},
+		{
+			name:      "success: jobs with the same spec share a unit",
+			specs:     jobSchedules{"cpu": "@hourly", "procedure": "@hourly"},
+			wantUnits: []string{"cpu,procedure", "network"},
+			success:   true,
+		},
+		{
+			name:    "failure: unknown job",
+			specs:   jobSchedules{"raid": "1h"},
+			wantErr: `unknown job "raid" (want one of cpu, network, procedure)`,
+			success: false,
+		},
+		{
+			name:    "failure: invalid spec",
+			specs:   jobSchedules{"procedure": "every hour"},
+			wantErr: "job procedure: cron expression",
+			success: false,
+		},
+		{
+			name:    "failure: negative duration",
+			specs:   jobSchedules{"cpu": "-1m"},
+			wantErr: "job cpu: interval must be positive",
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			units, err := planJobUnits(testJobs("cpu", "network", "procedure"), tt.specs, base, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("planJobUnits() error = %v, want to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planJobUnits() unexpected error: %v", err)
+			}
+			var got []string
+			for _, u := range units {
+				names := make([]string, len(u.Jobs))
+				for i, job := range u.Jobs {
+					names[i] = job.Name
+				}
+				got = append(got, strings.Join(names, ","))
+			}
+			if strings.Join(got, " | ") != strings.Join(tt.wantUnits, " | ") {
+				t.Errorf("units = %q, want %q", got, tt.wantUnits)
+			}
+		})
+	}
+}
+
+func TestJobSchedulesFlag(t *testing.T) {
+	j := jobSchedules{}
+	if err := j.Set("procedure=0 */6 * * *"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Set("procedure"); err == nil {
+		t.Error(`Set("procedure") expected error, got nil`)
+	}
+	if got := j.String(); got != "procedure=0 */6 * * *" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+// ===================== Scheduler =====================
+func TestScheduler_RecordsMissedRunsWithoutOverlap(t *testing.T) {
+	oldOutput := jobOutput
+	defer func() { jobOutput = oldOutput }()
+	var out bytes.Buffer
+	jobOutput = &out
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	var running, overlaps int32
+	slow := Job{Name: "procedure", Run: func(w io.Writer) {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		clock.now = clock.now.Add(25 * time.Second)
+		atomic.AddInt32(&running, -1)
+	}}
+	info := Job{Name: "cpu", Collector: true, Run: func(w io.Writer) { io.WriteString(w, "CPU cores: 4\n") }}
+
+	s := newScheduler(clock)
+	s.Run([]jobUnit{{Schedule: Schedule{Interval: 10 * time.Second, MaxIterations: 3, Mode: FixedRate}, Jobs: []Job{info, slow}}})
+
+	stats := s.Stats()
+	if got := stats["procedure"]; got.Runs != 3 || got.Missed != 4 || got.Running || !got.LastEnd.Equal(start.Add(85*time.Second)) {
+		t.Errorf("procedure stats = %+v, want 3 runs, 4 missed, ended at +85s", got)
+	}
+	if got := stats["cpu"]; got.Runs != 3 || got.Missed != 4 {
+		t.Errorf("cpu stats = %+v, want 3 runs and 4 missed", got)
+	}
+	if overlaps != 0 {
+		t.Errorf("procedure overlapped itself %d times", overlaps)
+	}
+	if got := strings.Count(out.String(), "=== Machine Info ===\nCPU cores: 4\n"); got != 3 {
+		t.Errorf("collector output blocks = %d, want 3 in:\n%s", got, out.String())
+	}
+	if !strings.Contains(out.String(), "Missed 2 scheduled run(s) of cpu, procedure while run 1 was still going") {
+		t.Errorf("output = %q, want missed runs reported", out.String())
+	}
+}
+
+func TestScheduler_RecordsMachineSnapshots(t *testing.T) {
+	oldOutput, oldHistory := jobOutput, history
+	defer func() { jobOutput, history = oldOutput, oldHistory }()
+	jobOutput = io.Discard
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	history = newTestHistory(t, HistoryLimits{}, clock)
+	jobs := []Job{
+		{Name: "cpu", Collector: true, Run: func(w io.Writer) { io.WriteString(w, "CPU cores: 4\n") }},
+		{Name: "memory", Collector: true, Run: func(w io.Writer) { io.WriteString(w, "Memory total: 8 GiB\n") }},
+		{Name: "procedure", Run: func(w io.Writer) {}},
+	}
+	newScheduler(clock).Run([]jobUnit{{Schedule: Schedule{Interval: time.Minute, MaxIterations: 2, Mode: FixedRate}, Jobs: jobs}})
+
+	records, _, err := history.Query(HistoryQuery{Kind: HistorySnapshot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Machine["memory"] != "Memory total: 8 GiB\n" || len(records[1].Machine) != 2 {
+		t.Errorf("snapshots = %+v, want one per iteration with cpu and memory output", records)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// storageModules are the kernel modules the storage procedures depend on,
+// reported on every iteration.
+var storageModules = []string{"loop", "dm_mod", "dm_thin_pool", "raid1", "dm_crypt"}
+
+// taintFlags maps each bit of /proc/sys/kernel/tainted to its letter and
+// meaning (see Documentation/admin-guide/tainted-kernels.rst).
+var taintFlags = []string{
+	"P proprietary module loaded",
+	"F module force loaded",
+	"S kernel running on out-of-spec system",
+	"R module force unloaded",
+	"M machine check exception",
+	"B bad page referenced",
+	"U taint requested by userspace",
+	"D kernel died recently (OOPS or BUG)",
+	"A ACPI table overridden",
+	"W kernel issued warning",
+	"C staging driver loaded",
+	"I firmware workaround applied",
+	"O out-of-tree module loaded",
+	"E unsigned module loaded",
+	"L soft lockup occurred",
+	"K kernel live patched",
+	"X auxiliary taint",
+	"T built with struct randomization plugin",
+	"N in-kernel test run",
+	"J userspace used mutating debug operation",
+}
+
+// ModuleStatus describes whether a kernel module can be used.
+type ModuleStatus string
+
+const (
+	ModuleLoaded    ModuleStatus = "loaded"
+	ModuleBuiltin   ModuleStatus = "builtin"
+	ModuleAvailable ModuleStatus = "available" // not loaded, but modprobe can load it
+	ModuleMissing   ModuleStatus = "missing"
+	ModuleUnknown   ModuleStatus = "unknown" // /lib/modules is not readable
+)
+
+// KernelModule is one line of /proc/modules.
+type KernelModule struct {
+	Name     string   `json:"name"`
+	Size     uint64   `json:"size"`
+	RefCount int      `json:"ref_count"`
+	UsedBy   []string `json:"used_by,omitempty"`
+	State    string   `json:"state"`
+}
+
+// KernelInfo describes the running kernel. Errors reading individual sources
+// are collected in Errors rather than failing the whole collector.
+type KernelInfo struct {
+	Release        string                  `json:"release"`
+	Version        string                  `json:"version"`
+	Machine        string                  `json:"machine"`
+	Cmdline        string                  `json:"cmdline"`
+	Uptime         time.Duration           `json:"uptime"`
+	BootTime       time.Time               `json:"boot_time"`
+	Tainted        uint64                  `json:"tainted"`
+	TaintFlags     []string                `json:"taint_flags,omitempty"`
+	Modules        []KernelModule          `json:"modules"`
+	StorageModules map[string]ModuleStatus `json:"storage_modules"`
+	Errors         []string                `json:"errors,omitempty"`
+}
+
+// parseUptime parses /proc/uptime, e.g. "350735.47 234388.90".
+func parseUptime(data []byte) (time.Duration, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("empty /proc/uptime")
+	}
+	secs, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing uptime: %w", err)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+// decodeTaint returns the description of every set bit of a taint mask.
+// Bits newer than taintFlags are reported by number.
+func decodeTaint(mask uint64) []string {
+	var flags []string
+	for bit := 0; bit < 64; bit++ {
+		if mask&(1<<bit) == 0 {
+			continue
+		}
+		if bit < len(taintFlags) {
+			flags = append(flags, taintFlags[bit])
+		} else {
+			flags = append(flags, fmt.Sprintf("bit %d", bit))
+		}
+	}
+	return flags
+}
+
+// parseProcModules parses /proc/modules lines such as
+// "dm_mod 184320 9 dm_thin_pool,dm_bufio, Live 0x0000000000000000".
+func parseProcModules(data []byte) ([]KernelModule, error) {
+	var modules []KernelModule
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("unexpected /proc/modules line %q", line)
+		}
+		m := KernelModule{Name: fields[0], State: fields[4]}
+		var err error
+		if m.Size, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", line, err)
+		}
+		if m.RefCount, err = strconv.Atoi(fields[2]); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", line, err)
+		}
+		for _, dep := range strings.Split(fields[3], ",") {
+			if dep != "" && dep != "-" {
+				m.UsedBy = append(m.UsedBy, dep)
+			}
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// moduleName converts a module path such as "kernel/drivers/md/dm-mod.ko.xz"
+// to the name the kernel uses, "dm_mod".
+func moduleName(path string) string {
+	name, _, _ := strings.Cut(filepath.Base(path), ".ko")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// parseModuleIndex collects module names from modules.builtin or
+// modules.dep ("path: deps...").
+func parseModuleIndex(data []byte) map[string]bool {
+	names := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		path, _, _ := strings.Cut(line, ":")
+		if path = strings.TrimSpace(path); path != "" {
+			names[moduleName(path)] = true
+		}
+	}
+	return names
+}
+
+// storageModuleStatus resolves each storage module: loaded per /proc/modules,
+// builtin per /sys/module or modules.builtin, available per modules.dep.
+// Without a readable modules.dep (the container image usually lacks
+// /lib/modules) a module that isn't loaded or builtin is unknown, not missing.
+func storageModuleStatus(sysRoot, modulesDir string, loaded []KernelModule) map[string]ModuleStatus {
+	isLoaded := make(map[string]bool, len(loaded))
+	for _, m := range loaded {
+		isLoaded[m.Name] = true
+	}
+	var builtin, dep map[string]bool
+	if data, err := ReadFile(filepath.Join(modulesDir, "modules.builtin")); err == nil {
+		builtin = parseModuleIndex(data)
+	}
+	if data, err := ReadFile(filepath.Join(modulesDir, "modules.dep")); err == nil {
+		dep = parseModuleIndex(data)
+	}
+
+	status := make(map[string]ModuleStatus, len(storageModules))
+	for _, name := range storageModules {
+		switch {
+		case isLoaded[name]:
+			status[name] = ModuleLoaded
+		case builtin[name] || fileExists(filepath.Join(sysRoot, "module", name)):
+			status[name] = ModuleBuiltin
+		case dep[name]:
+			status[name] = ModuleAvailable
+		case dep == nil:
+			status[name] = ModuleUnknown
+		default:
+			status[name] = ModuleMissing
+		}
+	}
+	return status
+}
+
+// readKernelInfo collects kernel details. Module files are looked up under
+// hostRoot, since /lib/modules belongs to the node, not the container image.
+func readKernelInfo(sysRoot, hostRoot string) KernelInfo {
+	var k KernelInfo
+	fail := func(what string, err error) { k.Errors = append(k.Errors, what+": "+err.Error()) }
+
+	if u, err := Uname(); err != nil {
+		fail("uname", err)
+	} else {
+		k.Release, k.Version, k.Machine = u.Release, u.Version, u.Machine
+	}
+	if data, err := ReadFile("/proc/cmdline"); err != nil {
+		fail("/proc/cmdline", err)
+	} else {
+		k.Cmdline = strings.TrimSpace(string(data))
+	}
+	if data, err := ReadFile("/proc/uptime"); err != nil {
+		fail("/proc/uptime", err)
+	} else if k.Uptime, err = parseUptime(data); err != nil {
+		fail("/proc/uptime", err)
+	} else {
+		k.BootTime = time.Now().Add(-k.Uptime).Truncate(time.Second)
+	}
+	if data, err := ReadFile("/proc/sys/kernel/tainted"); err != nil {
+		fail("/proc/sys/kernel/tainted", err)
+	} else if k.Tainted, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+		fail("/proc/sys/kernel/tainted", err)
+	} else {
+		k.TaintFlags = decodeTaint(k.Tainted)
+	}
+	if data, err := ReadFile("/proc/modules"); err != nil {
+		fail("/proc/modules", err)
+	} else if k.Modules, err = parseProcModules(data); err != nil {
+		fail("/proc/modules", err)
+	}
+	k.StorageModules = storageModuleStatus(sysRoot, hostPath(hostRoot, filepath.Join("/lib/modules", k.Release)), k.Modules)
+	return k
+}
+
+// formatKernelInfo renders kernel details for the machine info output.
+func formatKernelInfo(k KernelInfo) string {
+	taint := "not tainted"
+	if k.Tainted != 0 {
+		taint = fmt.Sprintf("%d (%s)", k.Tainted, strings.Join(k.TaintFlags, ", "))
+	}
+	var storage []string
+	for _, name := range storageModules {
+		storage = append(storage, fmt.Sprintf("%s=%s", name, k.StorageModules[name]))
+	}
+	lines := []string{
+		fmt.Sprintf("Kernel: %s %s %s", k.Release, k.Version, k.Machine),
+		"Kernel cmdline: " + orNone(k.Cmdline),
+		fmt.Sprintf("Uptime: %s (booted %s)", k.Uptime.Truncate(time.Second), k.BootTime.Format(time.RFC3339)),
+		"Kernel tainted: " + taint,
+		fmt.Sprintf("Kernel modules: %d loaded", len(k.Modules)),
+		"Storage modules: " + strings.Join(storage, " "),
+	}
+	for _, e := range k.Errors {
+		lines = append(lines, "Reading kernel info failed: "+e)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// requireModules returns a *SkipError naming the first required module that
+// is known to be missing. Unknown status is not treated as missing.
+func requireModules(k KernelInfo, names ...string) error {
+	for _, name := range names {
+		if k.StorageModules[name] == ModuleMissing {
+			return &SkipError{Reason: fmt.Sprintf("kernel module %s is not available for kernel %s", name, k.Release)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================== parseUptime =====================
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+		success bool
+	}{
+		{name: "success: normal", input: "350735.47 234388.90\n", want: 350735*time.Second + 470*time.Millisecond, success: true},
+		{name: "failure: empty", input: "", wantErr: true, success: false},
+		{name: "failure: not a number", input: "abc 1.0\n", wantErr: true, success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUptime([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseUptime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := got - tt.want; diff > time.Millisecond || diff < -time.Millisecond {
+				t.Errorf("parseUptime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== decodeTaint =====================
+func TestDecodeTaint(t *testing.T) {
+	tests := []struct {
+		name    string
+		mask    uint64
+		want    []string
+		success bool
+	}{
+		{name: "success: not tainted", mask: 0, want: nil, success: true},
+		{name: "success: proprietary and out-of-tree", mask: 1<<0 | 1<<12, want: []string{"P proprietary module loaded", "O out-of-tree module loaded"}, success: true},
+		{name: "success: unknown future bit", mask: 1 << 40, want: []string{"bit 40"}, success: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeTaint(tt.mask)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("decodeTaint(%d) = %q, want %q", tt.mask, got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== parseProcModules =====================
+func TestParseProcModules(t *testing.T) {
+	data, err := os.ReadFile("testdata/proc/modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modules, err := parseProcModules(data)
+	if err != nil {
+		t.Fatalf("parseProcModules() error = %v", err)
+	}
+	if len(modules) != 5 {
+		t.Fatalf("parseProcModules() returned %d modules, want 5", len(modules))
+	}
+	dm := modules[3]
+	if dm.Name != "dm_mod" || dm.Size != 184320 || dm.RefCount != 9 || dm.State != "Live" ||
+		strings.Join(dm.UsedBy, ",") != "dm_thin_pool,dm_bio_prison" {
+		t.Errorf("dm_mod = %+v", dm)
+	}
+	if len(modules[0].UsedBy) != 0 {
+		t.Errorf("dm_thin_pool UsedBy = %q, want none", modules[0].UsedBy)
+	}
+
+	for _, bad := range []string{"loop 1 2\n", "loop x 0 - Live 0x0\n", "loop 1 x - Live 0x0\n"} {
+		if _, err := parseProcModules([]byte(bad)); err == nil {
+			t.Errorf("parseProcModules(%q) error = nil, want error", bad)
+		}
+	}
+}
+
+func TestModuleName(t *testing.T) {
+	for path, want := range map[string]string{
+		"kernel/drivers/md/dm-mod.ko.xz":        "dm_mod",
+		"kernel/drivers/block/loop.ko":          "loop",
+		"kernel/drivers/md/dm-thin-pool.ko.zst": "dm_thin_pool",
+	} {
+		if got := moduleName(path); got != want {
+			t.Errorf("moduleName(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+// ===================== readKernelInfo =====================
+func TestReadKernelInfo(t *testing.T) {
+	oldReadFile, oldUname := ReadFile, Uname
+	defer func() { ReadFile, Uname = oldReadFile, oldUname }()
+
+	modulesDep := "kernel/drivers/md/dm-crypt.ko.zst: kernel/drivers/md/dm-mod.ko.zst\nkernel/drivers/md/dm-mod.ko.zst:\n"
+	tests := []struct {
+		name        string
+		hostRoot    string
+		files       map[string]string
+		unameErr    error
+		wantStatus  map[string]ModuleStatus
+		wantContain []string
+		success     bool
+	}{
+		{
+			name:     "success: host modules under host root",
+			hostRoot: "/host",
+			files: map[string]string{
+				"/proc/cmdline":            "BOOT_IMAGE=/vmlinuz-6.1.0-18-amd64 root=UUID=abcd ro quiet\n",
+				"/proc/uptime":             "93784.20 1000.00\n",
+				"/proc/sys/kernel/tainted": "4097\n",
+				"/host/lib/modules/6.1.0-18-amd64/modules.dep": modulesDep,
+			},
+			wantStatus: map[string]ModuleStatus{
+				"loop": ModuleBuiltin, "dm_mod": ModuleLoaded, "dm_thin_pool": ModuleLoaded,
+				"raid1": ModuleMissing, "dm_crypt": ModuleAvailable,
+			},
+			wantContain: []string{
+				"Kernel: 6.1.0-18-amd64 #1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1 x86_64",
+				"Kernel cmdline: BOOT_IMAGE=/vmlinuz-6.1.0-18-amd64 root=UUID=abcd ro quiet",
+				"Uptime: 26h3m4s (booted ",
+				"Kernel tainted: 4097 (P proprietary module loaded, O out-of-tree module loaded)",
+				"Kernel modules: 5 loaded",
+				"Storage modules: loop=builtin dm_mod=loaded dm_thin_pool=loaded raid1=missing dm_crypt=available",
+			},
+			success: true,
+		},
+		{
+			name:     "success: modules.builtin marks module builtin",
+			hostRoot: "/host",
+			files: map[string]string{
+				"/host/lib/modules/6.1.0-18-amd64/modules.builtin": "kernel/drivers/md/raid1.ko\n",
+				"/host/lib/modules/6.1.0-18-amd64/modules.dep":     modulesDep,
+				"/proc/sys/kernel/tainted":                         "0\n",
+			},
+			wantStatus:  map[string]ModuleStatus{"raid1": ModuleBuiltin},
+			wantContain: []string{"Kernel tainted: not tainted"},
+			success:     true,
+		},
+		{
+			name:        "failure: no /lib/modules makes missing modules unknown",
+			files:       map[string]string{},
+			wantStatus:  map[string]ModuleStatus{"loop": ModuleBuiltin, "raid1": ModuleUnknown, "dm_crypt": ModuleUnknown},
+			wantContain: []string{"Reading kernel info failed: /proc/cmdline: no such file", "Kernel cmdline: none"},
+			success:     false,
+		},
+		{
+			name:        "failure: uname fails",
+			files:       map[string]string{},
+			unameErr:    errors.New("not supported"),
+			wantContain: []string{"Reading kernel info failed: uname: not supported"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if path == "/proc/modules" {
+					return os.ReadFile("testdata/proc/modules")
+				}
+				if data, ok := tt.files[path]; ok {
+					return []byte(data), nil
+				}
+				return nil, errors.New("no such file")
+			}
+			Uname = func() (UnameInfo, error) {
+				if tt.unameErr != nil {
+					return UnameInfo{}, tt.unameErr
+				}
+				return UnameInfo{Release: "6.1.0-18-amd64", Version: "#1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1", Machine: "x86_64"}, nil
+			}
+
+			k := readKernelInfo("testdata/sys", tt.hostRoot)
+			for name, want := range tt.wantStatus {
+				if got := k.StorageModules[name]; got != want {
+					t.Errorf("StorageModules[%s] = %q, want %q", name, got, want)
+				}
+			}
+			got := formatKernelInfo(k)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatKernelInfo() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+// ===================== requireModules =====================
+func TestRequireModules(t *testing.T) {
+	k := KernelInfo{Release: "6.1.0", StorageModules: map[string]ModuleStatus{
+		"loop": ModuleLoaded, "dm_mod": ModuleMissing, "raid1": ModuleUnknown,
+	}}
+
+	if err := requireModules(k, "loop", "raid1"); err != nil {
+		t.Errorf("requireModules(loop, raid1) = %v, want nil (unknown is not missing)", err)
+	}
+	err := requireModules(k, "loop", "dm_mod")
+	var skip *SkipError
+	if !errors.As(err, &skip) {
+		t.Fatalf("requireModules(loop, dm_mod) = %v, want *SkipError", err)
+	}
+	if want := "kernel module dm_mod is not available for kernel 6.1.0"; skip.Reason != want {
+		t.Errorf("Reason = %q, want %q", skip.Reason, want)
+	}
+}
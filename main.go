@@ -1,56 +1,49 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// testFileSize is the size of the disk file the procedures fallocate in the
+// home directory; set by -test-size.
+var testFileSize = "100M"
+
 var (
-	ReadFile       = os.ReadFile
-	ExecOutput     = execOutput // run command, return stdout
-	RunBashCommand = runBashCommand
+	ReadFile   = os.ReadFile
+	ExecOutput = execOutput // run command, return stdout
 )
 
 func execOutput(name string, arg ...string) ([]byte, error) {
 	return exec.Command(name, arg...).Output()
 }
 
-func runBashCommand(command string) error {
-	cmd := exec.Command("bash", "-c", command)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(
-			"command failed: %s\nOutput:\n%s",
-			command,
-			string(out),
-		)
-	}
-	return nil
-}
-
+// readCpuCores returns the number of CPUs this process may run on, i.e. the
+// affinity/cpuset-limited count that nproc would print.
 func readCpuCores() int {
-	cpu_cores := 0
-
-	out, err := ExecOutput("nproc")
-
+	data, err := ReadFile("/proc/self/status")
 	if err != nil {
-		fmt.Println("Executing nproc failed:", err)
+		fmt.Println("Reading /proc/self/status failed:", err)
 		return 0
 	}
 
-	cpu_cores, err = strconv.Atoi(strings.TrimSpace(string(out)))
-
+	cpus, err := affinityFromStatus(data)
 	if err != nil {
-		fmt.Println("Parsing nproc output failed:", err)
+		fmt.Println("Parsing CPU affinity failed:", err)
 		return 0
 	}
 
-	return cpu_cores
+	return len(cpus)
 }
 
 func readMemoryFromData(data []byte) (usedKB, freeKB float64) {
@@ -102,127 +95,206 @@ func readMemory() (usedKB, freeKB float64) {
 	return readMemoryFromData(data)
 }
 
+// readDistroFromData returns the PRETTY_NAME of os-release content, or
+// "Invalid" when the content holds no os-release assignments at all.
 func readDistroFromData(data []byte) string {
-	lines := strings.Split(string(data), "\n")
-
-	if len(lines) == 0 || lines[0] == "" {
+	if len(parseOSReleaseVars(data)) == 0 {
 		fmt.Println("/etc/os-release output is not defined as expected")
 		return "Invalid"
 	}
+	return osReleaseFromData(data).PrettyName
+}
 
-	if strings.HasPrefix(lines[0], "PRETTY_NAME=") {
-		pretty := strings.TrimPrefix(lines[0], "PRETTY_NAME=")
-		pretty = strings.Trim(pretty, "\"")
-		return pretty
-	}
+// readDevices lists PCI devices from sysfs; it does not need pciutils.
+func readDevices() string {
+	return readDevicesFrom("/sys")
+}
 
-	fmt.Println("/etc/os-release output is not defined as expected")
-	return "Invalid"
+// SkipError reports that a procedure was not attempted because the node
+// can't support it; main prints it as skipped rather than failed. Transient
+// is set when the condition can clear by itself, such as a full disk.
+type SkipError struct {
+	Reason    string
+	Transient bool
 }
 
-func readDistro() string {
-	data, err := ReadFile("/etc/os-release")
+func (e *SkipError) Error() string {
+	return "skipped: " + e.Reason
+}
+
+// runCommand checks one step against the policy, runs it via RunArgv
+// (mockable in tests) and records it in the audit log and, through r, in its
+// run.
+func runCommand(step Step, r *Recorder) error {
+	argv := stepArgv(step)
+	r.stepStarted(argv)
+	rec, err := beginAudit(r.RunID(), commandArgv(step), argv)
 	if err != nil {
-		return "Error reading /etc/os-release: " + err.Error()
+		r.recordStep(StepResult{Argv: argv, ExitCode: rec.ExitCode, Error: err.Error()})
+		return err
+	}
+	err = RunArgv(argv, r)
+	rec = finishAudit(rec, err)
+	res := StepResult{Argv: argv, ExitCode: rec.ExitCode, DurationMS: rec.DurationMS}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		res.Output, res.Error = cmdErr.Output, cmdErr.Err.Error()
+	} else if err != nil {
+		res.Error = err.Error()
 	}
-	return readDistroFromData(data)
+	r.recordStep(res)
+	return err
 }
 
-func readDevicesFromOutput(output []byte, err error) string {
-	if err != nil {
-		return "Executing lspci failed:" + err.Error()
+// runCommands runs each step in order; stops on first error. Uses runCommand (and thus RunArgv).
+func runCommands(steps []Step, r *Recorder) error {
+	for _, step := range steps {
+		fmt.Printf("Executing: %s\n", shellJoin(stepArgv(step)))
+		if err := runCommand(step, r); err != nil {
+			return err
+		}
 	}
-	return string(output)
+	return nil
 }
 
-func readDevices() string {
-	out, err := ExecOutput("lspci")
-	return readDevicesFromOutput(out, err)
+// diskCommands returns the commands that runDiskProcedure executes.
+// This function is pure and testable without command execution.
+func diskCommands(homeDir string) []Step {
+	testDir := filepath.Join(homeDir, "file_systems_test")
+	diskFile := filepath.Join(testDir, "disk1")
+
+	return []Step{
+		cmd("mkdir", "-p", testDir),
+		cmd("fallocate", "-l", testFileSize, diskFile),
+		cmd("mkfs.ext4", "-F", diskFile),
+		privCmd("mkdir", "-p", "/mnt/disk1"),
+		privCmd("mount", "-o", "loop", diskFile, "/mnt/disk1"),
+		privShellCmd(`printf "Hello ext4\n" > "$1"`, "/mnt/disk1/test.txt"),
+		cmd("cat", "/mnt/disk1/test.txt"),
+		privCmd("umount", "/mnt/disk1"),
+		cmd("wipefs", "-a", diskFile),
+		cmd("rm", "-f", diskFile),
+		privCmd("rm", "-rf", "/mnt/disk1"),
+		cmd("rm", "-rf", testDir),
+	}
 }
 
-// runCommand runs one shell command via RunBashCommand (mockable in tests).
-func runCommand(command string) error {
-	return RunBashCommand(command)
+// testHomeDir returns the home directory the procedures put their disk file
+// in. It must be absolute so no argument derived from it reads as an option.
+func testHomeDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(homeDir) {
+		return "", fmt.Errorf("home directory %q is not absolute", homeDir)
+	}
+	return homeDir, nil
 }
 
-// runCommands runs each command in order; stops on first error. Uses runCommand (and thus RunBashCommand).
-func runCommands(commands []string) error {
-	for _, cmd := range commands {
-		fmt.Printf("Executing: %s\n", cmd)
-		if err := runCommand(cmd); err != nil {
-			return err
-		}
+// preflight prints the preflight report for a procedure and returns a
+// *SkipError if it can't succeed.
+func preflight(procedure string, req Requirements, r *Recorder) error {
+	root := "/"
+	if elevation.Root != "" {
+		root = elevation.Root // steps see the node's binaries and devices
 	}
-	return nil
+	report := runPreflight(procedure, req, root, elevation, r.RunID())
+	recordPreflight(procedure, report)
+	fmt.Println(formatPreflight(report))
+	return report.Err()
 }
 
-func diskCommands() []string {
-	return []string{
-		"mkdir -p ~/file_systems_test",
-		"cd ~/file_systems_test",
-		"fallocate -l 100M disk1",
-		"mkfs.ext4 -F disk1",
-		"sudo mkdir -p /mnt/disk1",
-		"sudo mount -o loop disk1 /mnt/disk1",
-		`sudo bash -c 'printf "Hello ext4\n" > /mnt/disk1/test.txt'`,
-		"cat /mnt/disk1/test.txt",
-		"sudo umount /mnt/disk1",
-		"wipefs -a disk1",
-		"rm -f disk1",
-		"sudo rm -rf /mnt/disk1",
-		"rm -rf ~/file_systems_test",
+// checkTestHeadroom refuses to start a procedure when the home directory's
+// filesystem, where the steps see it, can't hold the test file.
+func checkTestHeadroom() error {
+	need, err := parseSize(testFileSize)
+	if err != nil {
+		return err
+	}
+	homeDir, err := testHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	if err := checkHeadroom(filepath.Join(elevation.Root, homeDir), need); err != nil {
+		return &SkipError{Reason: err.Error(), Transient: true}
 	}
+	return nil
 }
 
-func runDiskProcedure() error {
+func runDiskProcedure(kernel KernelInfo, r *Recorder) error {
 	fmt.Println("=== Running Disk Procedure ===")
-	return runCommands(diskCommands())
+	if err := requireModules(kernel, diskRequirements.Modules...); err != nil {
+		return err
+	}
+	if err := preflight("disk", diskRequirements, r); err != nil {
+		return err
+	}
+	if err := checkTestHeadroom(); err != nil {
+		return err
+	}
+	homeDir, err := testHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return runCommands(diskCommands(homeDir), r)
 }
 
 // lvmCommands returns the list of commands that runLVMProcedure executes.
 // This function is pure and testable without command execution.
-func lvmCommands(homeDir, loopDevice string) []string {
-	testDir := fmt.Sprintf("%s/file_systems_test", homeDir)
-	diskFile := fmt.Sprintf("%s/disk1", testDir)
-
-	return []string{
-		fmt.Sprintf("mkdir -p %s", testDir),
-		fmt.Sprintf("fallocate -l 100M %s", diskFile),
-		fmt.Sprintf("sudo losetup %s %s", loopDevice, diskFile),
-		fmt.Sprintf("sudo pvcreate -y %s", loopDevice),
-		fmt.Sprintf("sudo vgcreate testvg %s", loopDevice),
-		"sudo lvcreate -Z n -l 50%FREE -n testlv1 testvg",
-		"sudo lvcreate -Z n -l 100%FREE -n testlv2 testvg",
-		"sudo vgchange -ay testvg",
-		"sudo vgscan --mknodes",
-		"sudo mkfs.ext4 -F /dev/mapper/testvg-testlv1",
-		"sudo mkfs.ext4 -F /dev/mapper/testvg-testlv2",
-		"sudo mkdir -p /mnt/lvm1 /mnt/lvm2",
-		"sudo mount /dev/mapper/testvg-testlv1 /mnt/lvm1",
-		"sudo mount /dev/mapper/testvg-testlv2 /mnt/lvm2",
-		`sudo bash -c 'printf "Hello LVM LV1\n" > /mnt/lvm1/test.txt'`,
-		`sudo bash -c 'printf "Hello LVM LV2\n" > /mnt/lvm2/test.txt'`,
-		"cat /mnt/lvm1/test.txt",
-		"cat /mnt/lvm2/test.txt",
-		"sudo umount /mnt/lvm1 /mnt/lvm2",
-		"sudo lvremove -y testvg/testlv1 testvg/testlv2",
-		"sudo vgremove -y testvg",
-		fmt.Sprintf("sudo pvremove -y %s", loopDevice),
-		fmt.Sprintf("sudo losetup -d %s", loopDevice),
-		fmt.Sprintf("rm -f %s", diskFile),
-		fmt.Sprintf("sudo rm -rf /mnt/lvm1 /mnt/lvm2 %s", testDir),
+func lvmCommands(homeDir, loopDevice string) []Step {
+	testDir := filepath.Join(homeDir, "file_systems_test")
+	diskFile := filepath.Join(testDir, "disk1")
+
+	return []Step{
+		cmd("mkdir", "-p", testDir),
+		cmd("fallocate", "-l", testFileSize, diskFile),
+		privCmd("losetup", loopDevice, diskFile),
+		privCmd("pvcreate", "-y", loopDevice),
+		privCmd("vgcreate", "testvg", loopDevice),
+		privCmd("lvcreate", "-Z", "n", "-l", "50%FREE", "-n", "testlv1", "testvg"),
+		privCmd("lvcreate", "-Z", "n", "-l", "100%FREE", "-n", "testlv2", "testvg"),
+		privCmd("vgchange", "-ay", "testvg"),
+		privCmd("vgscan", "--mknodes"),
+		privCmd("mkfs.ext4", "-F", "/dev/mapper/testvg-testlv1"),
+		privCmd("mkfs.ext4", "-F", "/dev/mapper/testvg-testlv2"),
+		privCmd("mkdir", "-p", "/mnt/lvm1", "/mnt/lvm2"),
+		privCmd("mount", "/dev/mapper/testvg-testlv1", "/mnt/lvm1"),
+		privCmd("mount", "/dev/mapper/testvg-testlv2", "/mnt/lvm2"),
+		privShellCmd(`printf "Hello LVM LV1\n" > "$1"`, "/mnt/lvm1/test.txt"),
+		privShellCmd(`printf "Hello LVM LV2\n" > "$1"`, "/mnt/lvm2/test.txt"),
+		cmd("cat", "/mnt/lvm1/test.txt"),
+		cmd("cat", "/mnt/lvm2/test.txt"),
+		privCmd("umount", "/mnt/lvm1", "/mnt/lvm2"),
+		privCmd("lvremove", "-y", "testvg/testlv1", "testvg/testlv2"),
+		privCmd("vgremove", "-y", "testvg"),
+		privCmd("pvremove", "-y", loopDevice),
+		privCmd("losetup", "-d", loopDevice),
+		cmd("rm", "-f", diskFile),
+		privCmd("rm", "-rf", "/mnt/lvm1", "/mnt/lvm2", testDir),
 	}
 }
 
-func innerLVMProcedure(homeDirGetter func() (string, error), loopDeviceGetter func() (string, error)) error {
-	fmt.Println("=== Running LVM Procedure ===")
+// lvmCleanupCommands returns the commands that remove leftovers of a failed
+// LVM run before the next one starts.
+func lvmCleanupCommands(homeDir, loopDevice string) []Step {
+	return []Step{
+		privCmd("umount", "/mnt/lvm1", "/mnt/lvm2"),
+		privCmd("lvremove", "-y", "testvg/testlv1", "testvg/testlv2"),
+		privCmd("vgremove", "-y", "testvg"),
+		privCmd("rm", "-rf", "/dev/testvg"),
+		privCmd("pvremove", "-y", loopDevice),
+		privCmd("losetup", "-d", loopDevice),
+		privCmd("rm", "-rf", "/mnt/lvm1", "/mnt/lvm2", filepath.Join(homeDir, "file_systems_test")),
+	}
+}
 
+func innerLVMProcedure(homeDirGetter func() (string, error), loopDeviceGetter func() (string, error), r *Recorder) error {
 	// Get home directory
 	homeDir, err := homeDirGetter()
 	if err != nil {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
-	testDir := fmt.Sprintf("%s/file_systems_test", homeDir)
 
 	// Find first free loop device
 	loopDevice, err := loopDeviceGetter()
@@ -231,76 +303,273 @@ func innerLVMProcedure(homeDirGetter func() (string, error), loopDeviceGetter fu
 	}
 	fmt.Printf("Using loop device: %s\n", loopDevice)
 
-	// Cleanup from previous failed runs
-	cleanupCommands := []string{
-		"sudo umount /mnt/lvm1 /mnt/lvm2 2>/dev/null || true",
-		"sudo lvremove -y testvg/testlv1 testvg/testlv2 2>/dev/null || true",
-		"sudo vgremove -y testvg 2>/dev/null || true",
-		"sudo rm -rf /dev/testvg 2>/dev/null || true",
-		fmt.Sprintf("sudo pvremove -y %s 2>/dev/null || true", loopDevice),
-		fmt.Sprintf("sudo losetup -d %s 2>/dev/null || true", loopDevice),
-		fmt.Sprintf("sudo rm -rf /mnt/lvm1 /mnt/lvm2 %s 2>/dev/null || true", testDir),
-	}
-
-	for _, cmd := range cleanupCommands {
-		runCommand(cmd)
+	// Cleanup from previous failed runs; these fail when there is nothing
+	// to clean up, so errors are ignored.
+	for _, step := range lvmCleanupCommands(homeDir, loopDevice) {
+		runCommand(step, r)
 	}
 
 	// Actual LVM procedure
 	commands := lvmCommands(homeDir, loopDevice)
-	return runCommands(commands)
+	return runCommands(commands, r)
 }
 
-func runLVMProcedure() error {
-	homeDirGetter := func() (string, error) {
-		return os.UserHomeDir()
+func runLVMProcedure(kernel KernelInfo, r *Recorder) error {
+	fmt.Println("=== Running LVM Procedure ===")
+	if err := requireModules(kernel, lvmRequirements.Modules...); err != nil {
+		return err
+	}
+	if err := preflight("LVM", lvmRequirements, r); err != nil {
+		return err
 	}
+	if err := checkTestHeadroom(); err != nil {
+		return err
+	}
+	homeDirGetter := testHomeDir
 	loopDeviceGetter := func() (string, error) {
-		loopDeviceBytes, err := ExecOutput("bash", "-c", "sudo losetup -f")
+		step := privCmd("losetup", "-f")
+		loopDeviceBytes, err := auditedOutput(r.RunID(), commandArgv(step), stepArgv(step))
 		if err != nil {
 			return "", err
 		}
 		return strings.TrimSpace(string(loopDeviceBytes)), nil
 	}
-	return innerLVMProcedure(homeDirGetter, loopDeviceGetter)
+	return innerLVMProcedure(homeDirGetter, loopDeviceGetter, r)
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		os.Exit(runHistoryCommand(os.Args[2:], os.Stdout))
+	}
 	useLVM := flag.Bool("lvm", false, "Use LVM procedure")
+	hostRoot := flag.String("host-root", "", "Path where the node's root filesystem is visible (e.g. /host or /proc/1/root)")
+	cpuSampleInterval := flag.Duration("cpu-sample-interval", time.Second, "Interval between the two /proc/stat samples used for CPU usage")
+	flag.StringVar(&testFileSize, "test-size", testFileSize, "Size of the test disk file, as accepted by fallocate -l (e.g. 100M, 1G)")
+	policyFile := flag.String("policy", "", "JSON allowlist of commands steps may run (default: built-in rules for the disk and LVM procedures)")
+	flag.StringVar(&auditLogPath, "audit-log", "", "Append-only JSON-lines log of every executed step, e.g. /var/log/linux-pod/audit.jsonl (default disabled); a step whose started record can't be written doesn't run")
+	dryRun := flag.Bool("dry-run", false, "Print the steps, cleanup steps and preflight requirements of the selected procedure and exit without running anything")
+	dryRunFormat := flag.String("dry-run-format", "text", "Output format of -dry-run: text or json")
+	interval := flag.Duration("interval", 15*time.Second, "Time between iterations of the main loop")
+	jitter := flag.Duration("jitter", 0, "Random extra delay in [0, jitter) added to every iteration, to spread out a fleet started together")
+	maxIterations := flag.Int("max-iterations", 0, "Stop after this many iterations (0 runs forever)")
+	once := flag.Bool("once", false, "Run a single iteration and exit (same as -max-iterations 1)")
+	scheduleMode := flag.String("schedule", FixedDelay, "fixed-delay waits -interval after each iteration ends; fixed-rate starts iterations every -interval regardless of how long they take")
+	jobScheds := jobSchedules{}
+	flag.Var(jobScheds, "job-schedule", "name=spec giving one job its own schedule; spec is a duration (1m) or a cron expression (\"0 */6 * * *\", @hourly). Repeatable. Jobs: cpu, memory, cgroup, distro, hardware, kernel, devices, block, network, filesystems, procedure")
+	flag.IntVar(&breakerConfig.Threshold, "breaker-threshold", breakerConfig.Threshold, "Consecutive procedure failures that open the circuit breaker")
+	flag.DurationVar(&breakerConfig.BaseDelay, "backoff-base", breakerConfig.BaseDelay, "Delay before retrying a procedure after its first failure; doubles with each consecutive failure")
+	flag.DurationVar(&breakerConfig.MaxDelay, "backoff-max", breakerConfig.MaxDelay, "Upper bound of the retry delay")
+	flag.DurationVar(&breakerConfig.Cooldown, "breaker-cooldown", breakerConfig.Cooldown, "How long an open circuit blocks a procedure before a single probe run is allowed")
+	httpAddr := flag.String("http-addr", ":9090", "Address of the HTTP server for /healthz and /readyz (empty disables)")
+	apiAddr := flag.String("api-addr", "127.0.0.1:9091", "Address of the HTTP server for the /v1 run and history API (empty disables); any address but loopback requires -api-token-file")
+	apiTokenFile := flag.String("api-token-file", "", "File holding the token /v1 requests must send as \"Authorization: Bearer <token>\"")
+	healthDeadline := flag.Duration("health-deadline", 10*time.Minute, "/healthz fails when a job has been running longer than this")
+	readyWindow := flag.Duration("ready-window", 15*time.Minute, "/readyz fails unless the procedure succeeded within this window; raise it when the procedure runs less often")
+	dataDir := flag.String("data-dir", "", "Directory of the run history store, e.g. an emptyDir or hostPath volume (default disabled); query it with the history subcommand")
+	historySize := flag.String("history-max-size", "10M", "Size at which the current history segment is rotated")
+	historyRotateAge := flag.Duration("history-rotate-age", 24*time.Hour, "Age at which the current history segment is rotated even if it is smaller than -history-max-size")
+	historyTotal := flag.String("history-max-total", "100M", "Total size of the history segments; the oldest are deleted beyond it")
+	historyAge := flag.Duration("history-max-age", 7*24*time.Hour, "History segments last written longer ago than this are deleted")
+	elevate := flag.String("elevate", "auto", "How privileged steps gain root: auto (none as root, sudo otherwise), none, sudo, doas or nsenter")
 	flag.Parse()
+	var err error
+	if elevation, err = selectElevation(*elevate, Geteuid()); err != nil {
+		fmt.Println("Invalid -elevate:", err)
+		os.Exit(2)
+	}
+	if _, err := parseSize(testFileSize); err != nil {
+		fmt.Println("Invalid -test-size:", err)
+		os.Exit(2)
+	}
+	if policy, err = loadPolicy(*policyFile); err != nil {
+		fmt.Println("Invalid -policy:", err)
+		os.Exit(2)
+	}
+	sched := Schedule{Interval: *interval, Jitter: *jitter, MaxIterations: *maxIterations, Mode: *scheduleMode}
+	if *once {
+		sched.MaxIterations = 1
+	}
+	if err := sched.Validate(); err != nil {
+		fmt.Println("Invalid schedule:", err)
+		os.Exit(2)
+	}
+	if err := breakerConfig.Validate(); err != nil {
+		fmt.Println("Invalid circuit breaker settings:", err)
+		os.Exit(2)
+	}
+	if *healthDeadline <= 0 || *readyWindow <= 0 {
+		fmt.Println("Invalid health settings: -health-deadline and -ready-window must be positive")
+		os.Exit(2)
+	}
+	if *dryRun {
+		plan, err := planProcedure(*useLVM)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		out, err := formatPlan(plan, *dryRunFormat)
+		if err != nil {
+			fmt.Println("Invalid -dry-run-format:", err)
+			os.Exit(2)
+		}
+		fmt.Println(out)
+		return
+	}
 
-	for {
-		fmt.Println("=== Machine Info ===")
-		cores := readCpuCores()
-		fmt.Printf("CPU cores: %d\n", cores)
-		used, free := readMemory()
-		fmt.Printf("Used memory: %.2f GB or %.2f MB\n", float64(used)/1024/1024, used/1024)
-		fmt.Printf("Free memory: %.2f GB or %.2f MB\n", float64(free)/1024/1024, free/1024)
-		distro := readDistro()
-		fmt.Printf("Distribution: %s\n", distro)
-		divices := readDevices()
-		fmt.Printf("Devices:\n%s\n", divices)
-
-		var err error
+	units, err := planJobUnits(mainJobs(*useLVM, *hostRoot, *cpuSampleInterval), jobScheds, sched, time.Now())
+	if err != nil {
+		fmt.Println("Invalid -job-schedule:", err)
+		os.Exit(2)
+	}
+	if *dataDir != "" {
+		limits := HistoryLimits{SegmentAge: *historyRotateAge, MaxAge: *historyAge}
+		var sizeErr, totalErr error
+		limits.SegmentSize, sizeErr = historyMaxSize(*historySize)
+		limits.TotalSize, totalErr = historyMaxSize(*historyTotal)
+		err := errors.Join(sizeErr, totalErr)
+		if err == nil {
+			history, err = openHistoryStore(*dataDir, limits, realClock{})
+		}
+		if err != nil {
+			fmt.Println("Invalid history store:", err)
+			os.Exit(2)
+		}
+	}
+	scheduler := newScheduler(realClock{})
+	if *httpAddr != "" {
+		procedure := "disk"
 		if *useLVM {
-			err = runLVMProcedure()
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				fmt.Println("=== LVM Procedure Completed Successfully ===")
-				fmt.Println()
+			procedure = "lvm"
+		}
+		mux := http.NewServeMux()
+		newHealthChecker(realClock{}, scheduler, procedure, *healthDeadline, *readyWindow).Register(mux)
+		serveHTTP("health endpoints", *httpAddr, mux)
+	}
+	var api *RunAPI
+	if *apiAddr != "" {
+		token, err := readAPIToken(*apiTokenFile)
+		if err == nil {
+			err = checkAPIAddr(*apiAddr, token)
+		}
+		if err != nil {
+			fmt.Println("Invalid API server:", err)
+			os.Exit(2)
+		}
+		mux := http.NewServeMux()
+		api = newRunAPI(runs, *hostRoot)
+		api.Register(mux)
+		if history != nil {
+			history.Register(mux)
+		}
+		serveHTTP("HTTP API", *apiAddr, requireToken(token, mux))
+	}
+	scheduler.Run(units)
+	if api != nil {
+		// An on-demand run still in progress would leave its mounts, volume
+		// group and loop device behind.
+		api.Wait()
+	}
+}
 
-			}
-		} else {
-			err = runDiskProcedure()
-			if err != nil {
-				fmt.Println(err)
+// serveHTTP serves h on addr in the background, exiting if addr can't be
+// listened on.
+func serveHTTP(what, addr string, h http.Handler) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fmt.Println("HTTP server failed:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Serving %s on %s\n", what, ln.Addr())
+	go http.Serve(ln, h)
+}
+
+// cgroupPSIDir returns the cgroup v2 directory whose *.pressure files belong
+// to this container, or "" to read only /proc/pressure.
+func cgroupPSIDir() string {
+	if limits, err := readCgroupLimits("/sys/fs/cgroup"); err == nil && limits.Version == 2 {
+		return limits.Dir
+	}
+	return ""
+}
+
+// mainJobs returns the collectors and the procedure in the order the output
+// shows them; each can be given its own schedule with -job-schedule.
+func mainJobs(useLVM bool, hostRoot string, cpuSampleInterval time.Duration) []Job {
+	var netSample NetSample
+	return []Job{
+		{Name: "cpu", Collector: true, Run: func(w io.Writer) {
+			fmt.Fprintf(w, "CPU cores: %d\n", readCpuCores())
+			fmt.Fprintln(w, formatCPUInfo(readCPUInfo("/sys")))
+			fmt.Fprintln(w, readCPULoad(cpuSampleInterval))
+		}},
+		{Name: "memory", Collector: true, Run: func(w io.Writer) {
+			used, free := readMemory()
+			fmt.Fprintf(w, "Used memory: %.2f GB or %.2f MB\n", float64(used)/1024/1024, used/1024)
+			fmt.Fprintf(w, "Free memory: %.2f GB or %.2f MB\n", float64(free)/1024/1024, free/1024)
+		}},
+		{Name: "cgroup", Collector: true, Run: func(w io.Writer) {
+			psiCgroupDir := ""
+			if limits, err := readCgroupLimits("/sys/fs/cgroup"); err != nil {
+				fmt.Fprintln(w, "Reading cgroup limits failed:", err)
 			} else {
-				fmt.Println("=== Disk Procedure Completed Successfully ===")
-				fmt.Println()
+				used, free := readMemory()
+				fmt.Fprintln(w, formatCgroupLimits(limits, used+free, len(readCPUInfo("/sys").Online)))
+				if limits.Version == 2 {
+					psiCgroupDir = limits.Dir
+				}
 			}
-		}
+			fmt.Fprintln(w, formatPSISnapshot(readPSISnapshot(psiCgroupDir)))
+		}},
+		{Name: "distro", Collector: true, Run: func(w io.Writer) {
+			distro := readDistroInfo(hostRoot)
+			fmt.Fprintf(w, "Distribution: %s\n", formatDistro(distro.Container, distro.ContainerErr))
+			fmt.Fprintf(w, "Host distribution: %s\n", formatDistro(distro.Host, distro.HostErr))
+			fmt.Fprintf(w, "Host kernel: %s\n", distro.KernelRelease)
+		}},
+		{Name: "hardware", Collector: true, Run: func(w io.Writer) {
+			dmi := readDMIInfo("/sys")
+			fmt.Fprintln(w, formatDMIInfo(dmi))
+			fmt.Fprintln(w, formatRuntimeEnv(readRuntimeEnv("/", "/sys", readCPUInfo("/sys"), dmi)))
+		}},
+		{Name: "kernel", Collector: true, Run: func(w io.Writer) {
+			fmt.Fprintln(w, formatKernelInfo(readKernelInfo("/sys", hostRoot)))
+		}},
+		{Name: "devices", Collector: true, Run: func(w io.Writer) {
+			fmt.Fprintf(w, "Devices:\n%s\n", readDevices())
+		}},
+		{Name: "block", Collector: true, Run: func(w io.Writer) {
+			fmt.Fprintf(w, "Block devices:\n%s\n", readBlockDevicesFrom("/sys"))
+		}},
+		{Name: "network", Collector: true, Run: func(w io.Writer) {
+			var network string
+			network, netSample = readNetwork("/sys", netSample)
+			fmt.Fprintf(w, "Network interfaces:\n%s\n", network)
+		}},
+		{Name: "filesystems", Collector: true, Run: func(w io.Writer) {
+			fmt.Fprintf(w, "Filesystems:\n%s\n", readFilesystemUsage())
+		}},
+		{Name: "procedure", Run: func(w io.Writer) {
+			runProcedureJob(w, useLVM, hostRoot)
+		}},
+	}
+}
 
-		time.Sleep(15 * time.Second)
+// runProcedureJob runs the disk or LVM procedure, unless its circuit breaker
+// defers it or another run is in progress.
+func runProcedureJob(w io.Writer, useLVM bool, hostRoot string) {
+	name := "disk"
+	if useLVM {
+		name = "lvm"
+	}
+	title := procedureTitle(name)
+	if ok, reason := procedureBreaker(title).Allow(time.Now()); !ok {
+		fmt.Fprintf(w, "=== %s Procedure Deferred: %s ===\n", title, reason)
+		return
+	}
+	run, err := runs.Start(name, "schedule")
+	if err != nil {
+		fmt.Fprintf(w, "=== %s Procedure Deferred: %s ===\n", title, err)
+		return
 	}
+	executeRun(w, runs, run, hostRoot)
 }
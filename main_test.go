@@ -10,33 +10,47 @@ import (
 
 // ===================== readCpuCores =====================
 func TestReadCpuCores(t *testing.T) {
-	oldExec := ExecOutput
-	defer func() { ExecOutput = oldExec }()
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
 
 	tests := []struct {
 		name      string
-		mockOut   []byte
+		mockData  []byte
 		mockErr   error
 		wantCores int
 		success   bool
 	}{
 		{
-			name:      "success: valid nproc output",
-			mockOut:   []byte("8"),
+			name:      "success: contiguous affinity",
+			mockData:  []byte("Name:\tapp\nCpus_allowed:\tff\nCpus_allowed_list:\t0-7\n"),
 			mockErr:   nil,
 			wantCores: 8,
 			success:   true,
 		},
 		{
-			name:      "failure: nproc execution fails",
-			mockOut:   nil,
-			mockErr:   errors.New("exec failed"),
+			name:      "success: cpuset-limited affinity",
+			mockData:  []byte("Cpus_allowed_list:\t2,4-5\n"),
+			mockErr:   nil,
+			wantCores: 3,
+			success:   true,
+		},
+		{
+			name:      "failure: status read fails",
+			mockData:  nil,
+			mockErr:   errors.New("read failed"),
+			wantCores: 0,
+			success:   false,
+		},
+		{
+			name:      "failure: Cpus_allowed_list missing",
+			mockData:  []byte("Name:\tapp\n"),
+			mockErr:   nil,
 			wantCores: 0,
 			success:   false,
 		},
 		{
 			name:      "failure: parse fails (non-numeric)",
-			mockOut:   []byte("NaN"),
+			mockData:  []byte("Cpus_allowed_list:\tNaN\n"),
 			mockErr:   nil,
 			wantCores: 0,
 			success:   false,
@@ -45,11 +59,11 @@ func TestReadCpuCores(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ExecOutput = func(name string, _ ...string) ([]byte, error) {
-				if name == "nproc" {
-					return tt.mockOut, tt.mockErr
+			ReadFile = func(path string) ([]byte, error) {
+				if path != "/proc/self/status" {
+					return nil, errors.New("unexpected path")
 				}
-				return nil, nil
+				return tt.mockData, tt.mockErr
 			}
 			got := readCpuCores()
 			if got != tt.wantCores {
@@ -57,7 +71,7 @@ func TestReadCpuCores(t *testing.T) {
 			}
 		})
 	}
-	ExecOutput = oldExec
+	ReadFile = oldReadFile
 }
 
 // ===================== readMemoryFromData (pure) =====================
@@ -170,8 +184,14 @@ func TestReadDistroFromData(t *testing.T) {
 			success:  true,
 		},
 		{
-			name:     "failure: no PRETTY_NAME",
-			input:    `NAME="Ubuntu"\nVERSION="22.04.3 LTS"`,
+			name:     "success: no PRETTY_NAME defaults to Linux",
+			input:    "NAME=\"Ubuntu\"\nVERSION=\"22.04.3 LTS\"",
+			expected: "Linux",
+			success:  true,
+		},
+		{
+			name:     "failure: no assignments",
+			input:    "# just a comment\n",
 			expected: "Invalid",
 			success:  false,
 		},
@@ -182,16 +202,16 @@ func TestReadDistroFromData(t *testing.T) {
 			success:  false,
 		},
 		{
-			name:     "failure: empty first line",
+			name:     "success: empty first line",
 			input:    "\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"",
-			expected: "Invalid",
-			success:  false,
+			expected: "Ubuntu 22.04.3 LTS",
+			success:  true,
 		},
 		{
-			name:     "failure: PRETTY_NAME not at start",
+			name:     "success: PRETTY_NAME not at start",
 			input:    "NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"",
-			expected: "Invalid",
-			success:  false,
+			expected: "Ubuntu 22.04.3 LTS",
+			success:  true,
 		},
 		{
 			name:     "success: quotes only",
@@ -211,161 +231,10 @@ func TestReadDistroFromData(t *testing.T) {
 	}
 }
 
-// ===================== readDistro =====================
-func TestReadDistro(t *testing.T) {
-	oldReadFile := ReadFile
-	defer func() { ReadFile = oldReadFile }()
-
-	tests := []struct {
-		name        string
-		mockData    []byte
-		mockErr     error
-		wantContain string
-		wantError   bool
-		success     bool
-	}{
-		{
-			name:        "success: valid os-release",
-			mockData:    []byte(`PRETTY_NAME="Ubuntu 22.04.3 LTS"`),
-			mockErr:     nil,
-			wantContain: "Ubuntu 22.04.3 LTS",
-			wantError:   false,
-			success:     true,
-		},
-		{
-			name:        "failure: file read error",
-			mockData:    nil,
-			mockErr:     errors.New("permission denied"),
-			wantContain: "Error reading /etc/os-release",
-			wantError:   true,
-			success:     false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ReadFile = func(path string) ([]byte, error) {
-				if path != "/etc/os-release" {
-					return nil, errors.New("unexpected path")
-				}
-				return tt.mockData, tt.mockErr
-			}
-			got := readDistro()
-			if tt.wantError && !strings.Contains(got, "Error reading") {
-				t.Errorf("readDistro() = %q, want error message", got)
-			}
-			if tt.wantContain != "" && !strings.Contains(got, tt.wantContain) {
-				t.Errorf("readDistro() = %q, want to contain %q", got, tt.wantContain)
-			}
-		})
-	}
-	ReadFile = oldReadFile
-}
-
-// ===================== readDevicesFromOutput =====================
-func TestReadDevicesFromOutput(t *testing.T) {
-	tests := []struct {
-		name     string
-		output   []byte
-		err      error
-		expected string
-		success  bool
-	}{
-		{
-			name:     "success: normal output",
-			output:   []byte("00:00.0 Host bridge: Intel Corporation Device\n00:02.0 VGA compatible controller: Intel Corporation Device"),
-			err:      nil,
-			expected: "00:00.0 Host bridge: Intel Corporation Device\n00:02.0 VGA compatible controller: Intel Corporation Device",
-			success:  true,
-		},
-		{
-			name:     "success: empty output",
-			output:   []byte(""),
-			err:      nil,
-			expected: "",
-			success:  true,
-		},
-		{
-			name:     "failure: command error",
-			output:   nil,
-			err:      errors.New("executable file not found"),
-			expected: "Executing lspci failed:",
-			success:  false,
-		},
-		{
-			name:     "failure: command error with message",
-			output:   []byte("some output"),
-			err:      errors.New("command failed"),
-			expected: "Executing lspci failed:command failed",
-			success:  false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := readDevicesFromOutput(tt.output, tt.err)
-			if !strings.Contains(got, tt.expected) && got != tt.expected {
-				t.Errorf("readDevicesFromOutput() = %q, want to contain or equal %q", got, tt.expected)
-			}
-		})
-	}
-}
-
-// ===================== readDevices =====================
-func TestReadDevices(t *testing.T) {
-	oldExec := ExecOutput
-	defer func() { ExecOutput = oldExec }()
-
-	tests := []struct {
-		name        string
-		mockOut     []byte
-		mockErr     error
-		wantContain string
-		wantError   bool
-		success     bool
-	}{
-		{
-			name:        "success: lspci output",
-			mockOut:     []byte("00:00.0 Host bridge: Intel Corporation Device"),
-			mockErr:     nil,
-			wantContain: "00:00.0 Host bridge",
-			wantError:   false,
-			success:     true,
-		},
-		{
-			name:        "failure: lspci execution fails",
-			mockOut:     nil,
-			mockErr:     errors.New("exec failed"),
-			wantContain: "Executing lspci failed",
-			wantError:   true,
-			success:     false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ExecOutput = func(name string, _ ...string) ([]byte, error) {
-				if name == "lspci" {
-					return tt.mockOut, tt.mockErr
-				}
-				return nil, nil
-			}
-			got := readDevices()
-			if tt.wantError && !strings.Contains(got, "Executing lspci failed") {
-				t.Errorf("readDevices() = %q, want error message", got)
-			}
-			if tt.wantContain != "" && !strings.Contains(got, tt.wantContain) {
-				t.Errorf("readDevices() = %q, want to contain %q", got, tt.wantContain)
-			}
-		})
-	}
-	ExecOutput = oldExec
-}
-
 // ===================== runCommand =====================
 func TestRunCommand(t *testing.T) {
-	oldRun := RunBashCommand
-	defer func() { RunBashCommand = oldRun }()
+	oldRun := RunArgv
+	defer func() { RunArgv = oldRun }()
 
 	tests := []struct {
 		name    string
@@ -402,13 +271,14 @@ func TestRunCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			RunBashCommand = func(cmd string) error {
+			RunArgv = func(argv []string, _ *Recorder) error {
+				cmd := shellJoin(argv)
 				if cmd != tt.cmd {
 					return nil
 				}
 				return tt.mockErr
 			}
-			err := runCommand(tt.cmd)
+			err := runCommand(cmd(strings.Fields(tt.cmd)...), nil)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("runCommand() expected error, got nil")
@@ -424,33 +294,34 @@ func TestRunCommand(t *testing.T) {
 			}
 		})
 	}
-	RunBashCommand = oldRun
+	RunArgv = oldRun
 }
 
 // ===================== runCommands / diskCommands =====================
 
 func TestRunDiskProcedure_CommandsOrder(t *testing.T) {
-	oldRun := RunBashCommand
-	defer func() { RunBashCommand = oldRun }()
+	oldRun := RunArgv
+	defer func() { RunArgv = oldRun }()
 
 	var got []string
-	RunBashCommand = func(cmd string) error {
+	RunArgv = func(argv []string, _ *Recorder) error {
+		cmd := shellJoin(argv)
 		got = append(got, cmd)
 		return nil
 	}
 
-	err := runCommands(diskCommands())
+	err := runCommands(diskCommands("/home/test"), nil)
 	if err != nil {
-		t.Fatalf("runCommands(diskCommands()): %v", err)
+		t.Fatalf("runCommands(diskCommands(...)): %v", err)
 	}
 
-	want := diskCommands()
+	want := diskCommands("/home/test")
 	if len(got) != len(want) {
 		t.Fatalf("commands count: got %d, want %d", len(got), len(want))
 	}
-	for i, cmd := range want {
-		if i >= len(got) || got[i] != cmd {
-			t.Errorf("command[%d]: got %q, want %q", i, got[i], cmd)
+	for i, step := range want {
+		if i >= len(got) || got[i] != shellJoin(stepArgv(step)) {
+			t.Errorf("command[%d]: got %q, want %q", i, got[i], shellJoin(stepArgv(step)))
 		}
 	}
 }
@@ -464,7 +335,7 @@ func TestRunDiskProcedure_ErrorPropagation(t *testing.T) {
 	}{
 		{
 			name:          "failure: error on first command (mkdir)",
-			failingCmd:    "mkdir -p ~/file_systems_test",
+			failingCmd:    "mkdir -p /home/test/file_systems_test",
 			expectedError: "command failed",
 			success:       false,
 		},
@@ -482,13 +353,13 @@ func TestRunDiskProcedure_ErrorPropagation(t *testing.T) {
 		},
 		{
 			name:          "failure: error on mount",
-			failingCmd:    "sudo mount",
+			failingCmd:    "mount -o loop",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on last command",
-			failingCmd:    "rm -rf ~/file_systems_test",
+			failingCmd:    "rm -rf /home/test/file_systems_test",
 			expectedError: "command failed",
 			success:       false,
 		},
@@ -496,15 +367,16 @@ func TestRunDiskProcedure_ErrorPropagation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			oldRun := RunBashCommand
-			defer func() { RunBashCommand = oldRun }()
-			RunBashCommand = func(cmd string) error {
+			oldRun := RunArgv
+			defer func() { RunArgv = oldRun }()
+			RunArgv = func(argv []string, _ *Recorder) error {
+				cmd := shellJoin(argv)
 				if strings.Contains(cmd, tt.failingCmd) {
 					return fmt.Errorf("%s: %s", tt.expectedError, cmd)
 				}
 				return nil
 			}
-			err := runCommands(diskCommands())
+			err := runCommands(diskCommands("/home/test"), nil)
 			if err == nil {
 				t.Fatal("expected error, got nil")
 			}
@@ -516,12 +388,13 @@ func TestRunDiskProcedure_ErrorPropagation(t *testing.T) {
 }
 
 func TestRunDiskProcedure_EarlyExitOnError(t *testing.T) {
-	oldRun := RunBashCommand
-	defer func() { RunBashCommand = oldRun }()
+	oldRun := RunArgv
+	defer func() { RunArgv = oldRun }()
 
 	var executed []string
 	failingIndex := 3
-	RunBashCommand = func(cmd string) error {
+	RunArgv = func(argv []string, _ *Recorder) error {
+		cmd := shellJoin(argv)
 		executed = append(executed, cmd)
 		if len(executed) == failingIndex+1 {
 			return fmt.Errorf("command failed: %s", cmd)
@@ -529,8 +402,8 @@ func TestRunDiskProcedure_EarlyExitOnError(t *testing.T) {
 		return nil
 	}
 
-	commands := diskCommands()
-	err := runCommands(commands)
+	commands := diskCommands("/home/test")
+	err := runCommands(commands, nil)
 
 	if err == nil {
 		t.Fatal("expected error, got nil")
@@ -542,11 +415,12 @@ func TestRunDiskProcedure_EarlyExitOnError(t *testing.T) {
 
 // ===================== lvmCommands / innerLVMProcedure =====================
 func TestRunLVMProcedure_CommandsOrder(t *testing.T) {
-	oldRun := RunBashCommand
-	defer func() { RunBashCommand = oldRun }()
+	oldRun := RunArgv
+	defer func() { RunArgv = oldRun }()
 
 	var got []string
-	RunBashCommand = func(cmd string) error {
+	RunArgv = func(argv []string, _ *Recorder) error {
+		cmd := shellJoin(argv)
 		got = append(got, cmd)
 		return nil
 	}
@@ -554,7 +428,7 @@ func TestRunLVMProcedure_CommandsOrder(t *testing.T) {
 	homeDir := "/home/test"
 	loopDevice := "/dev/loop0"
 	commands := lvmCommands(homeDir, loopDevice)
-	err := runCommands(commands)
+	err := runCommands(commands, nil)
 	if err != nil {
 		t.Fatalf("runCommands(lvmCommands(...)): %v", err)
 	}
@@ -563,9 +437,9 @@ func TestRunLVMProcedure_CommandsOrder(t *testing.T) {
 	if len(got) != len(want) {
 		t.Fatalf("commands count: got %d, want %d", len(got), len(want))
 	}
-	for i, cmd := range want {
-		if i >= len(got) || got[i] != cmd {
-			t.Errorf("command[%d]: got %q, want %q", i, got[i], cmd)
+	for i, step := range want {
+		if i >= len(got) || got[i] != shellJoin(stepArgv(step)) {
+			t.Errorf("command[%d]: got %q, want %q", i, got[i], shellJoin(stepArgv(step)))
 		}
 	}
 }
@@ -589,43 +463,43 @@ func TestRunLVMProcedure_ErrorPropagation(t *testing.T) {
 		},
 		{
 			name:          "failure: error on losetup",
-			failingCmd:    "sudo losetup",
+			failingCmd:    "losetup /dev/loop0",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on pvcreate",
-			failingCmd:    "sudo pvcreate",
+			failingCmd:    "pvcreate",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on vgcreate",
-			failingCmd:    "sudo vgcreate",
+			failingCmd:    "vgcreate",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on lvcreate",
-			failingCmd:    "sudo lvcreate",
+			failingCmd:    "lvcreate",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on mkfs",
-			failingCmd:    "sudo mkfs.ext4",
+			failingCmd:    "mkfs.ext4",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on mount",
-			failingCmd:    "sudo mount",
+			failingCmd:    "mount /dev/mapper",
 			expectedError: "command failed",
 			success:       false,
 		},
 		{
 			name:          "failure: error on last command",
-			failingCmd:    "sudo rm -rf /mnt/lvm1",
+			failingCmd:    "rm -rf /mnt/lvm1",
 			expectedError: "command failed",
 			success:       false,
 		},
@@ -633,15 +507,16 @@ func TestRunLVMProcedure_ErrorPropagation(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			oldRun := RunBashCommand
-			defer func() { RunBashCommand = oldRun }()
-			RunBashCommand = func(cmd string) error {
+			oldRun := RunArgv
+			defer func() { RunArgv = oldRun }()
+			RunArgv = func(argv []string, _ *Recorder) error {
+				cmd := shellJoin(argv)
 				if strings.Contains(cmd, tt.failingCmd) {
 					return fmt.Errorf("%s: %s", tt.expectedError, cmd)
 				}
 				return nil
 			}
-			err := runCommands(commands)
+			err := runCommands(commands, nil)
 			if err == nil {
 				t.Fatal("expected error, got nil")
 			}
@@ -653,12 +528,13 @@ func TestRunLVMProcedure_ErrorPropagation(t *testing.T) {
 }
 
 func TestRunLVMProcedure_EarlyExitOnError(t *testing.T) {
-	oldRun := RunBashCommand
-	defer func() { RunBashCommand = oldRun }()
+	oldRun := RunArgv
+	defer func() { RunArgv = oldRun }()
 
 	var executed []string
 	failingIndex := 4
-	RunBashCommand = func(cmd string) error {
+	RunArgv = func(argv []string, _ *Recorder) error {
+		cmd := shellJoin(argv)
 		executed = append(executed, cmd)
 		if len(executed) == failingIndex+1 {
 			return fmt.Errorf("command failed: %s", cmd)
@@ -669,7 +545,7 @@ func TestRunLVMProcedure_EarlyExitOnError(t *testing.T) {
 	homeDir := "/home/test"
 	loopDevice := "/dev/loop0"
 	commands := lvmCommands(homeDir, loopDevice)
-	err := runCommands(commands)
+	err := runCommands(commands, nil)
 
 	if err == nil {
 		t.Fatal("expected error, got nil")
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is one line of /proc/<pid>/mountinfo (see proc(5)).
+type MountInfo struct {
+	ID           int    `json:"id"`
+	ParentID     int    `json:"parent_id"`
+	MajorMinor   string `json:"major_minor"`
+	Root         string `json:"root"`
+	MountPoint   string `json:"mount_point"`
+	Options      string `json:"options"`
+	FSType       string `json:"fs_type"`
+	Source       string `json:"source"`
+	SuperOptions string `json:"super_options"`
+}
+
+// parseMountInfo parses mountinfo content. Malformed lines are reported and skipped.
+func parseMountInfo(data []byte) []MountInfo {
+	var mounts []MountInfo
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m, err := parseMountInfoLine(line)
+		if err != nil {
+			fmt.Printf("Skipping malformed mountinfo line %q: %v\n", line, err)
+			continue
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+func parseMountInfoLine(line string) (MountInfo, error) {
+	pre, post, ok := strings.Cut(line, " - ")
+	if !ok {
+		return MountInfo{}, fmt.Errorf("missing separator")
+	}
+	fields := strings.Fields(pre)
+	tail := strings.Fields(post)
+	if len(fields) < 6 || len(tail) < 2 {
+		return MountInfo{}, fmt.Errorf("not enough fields")
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return MountInfo{}, fmt.Errorf("mount ID: %w", err)
+	}
+	parent, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return MountInfo{}, fmt.Errorf("parent ID: %w", err)
+	}
+	m := MountInfo{
+		ID:         id,
+		ParentID:   parent,
+		MajorMinor: fields[2],
+		Root:       unescapeMountField(fields[3]),
+		MountPoint: unescapeMountField(fields[4]),
+		Options:    fields[5],
+		FSType:     tail[0],
+		Source:     unescapeMountField(tail[1]),
+	}
+	if len(tail) > 2 {
+		m.SuperOptions = tail[2]
+	}
+	return m, nil
+}
+
+// unescapeMountField decodes the \ooo octal escapes the kernel uses for
+// space, tab, newline and backslash in mountinfo paths.
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// readMountInfo reads and parses /proc/self/mountinfo.
+func readMountInfo() ([]MountInfo, error) {
+	data, err := ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	return parseMountInfo(data), nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// ===================== parseMountInfo =====================
+func TestParseMountInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []MountInfo
+		success bool
+	}{
+		{
+			name:  "success: root mount with optional fields",
+			input: "22 1 8:1 / / rw,relatime shared:1 master:2 - ext4 /dev/sda1 rw,errors=remount-ro\n",
+			want: []MountInfo{{
+				ID: 22, ParentID: 1, MajorMinor: "8:1", Root: "/", MountPoint: "/", Options: "rw,relatime",
+				FSType: "ext4", Source: "/dev/sda1", SuperOptions: "rw,errors=remount-ro",
+			}},
+			success: true,
+		},
+		{
+			name:  "success: octal escapes decoded",
+			input: `28 22 9:0 / /srv/my\040data\011x rw - xfs /dev/md0 rw` + "\n",
+			want: []MountInfo{{
+				ID: 28, ParentID: 22, MajorMinor: "9:0", Root: "/", MountPoint: "/srv/my data\tx", Options: "rw",
+				FSType: "xfs", Source: "/dev/md0", SuperOptions: "rw",
+			}},
+			success: true,
+		},
+		{
+			name:    "failure: missing separator",
+			input:   "22 1 8:1 / / rw ext4 /dev/sda1 rw\n",
+			want:    nil,
+			success: false,
+		},
+		{
+			name:    "failure: non-numeric mount ID",
+			input:   "x 1 8:1 / / rw - ext4 /dev/sda1 rw\n",
+			want:    nil,
+			success: false,
+		},
+		{
+			name:    "failure: empty input",
+			input:   "",
+			want:    nil,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMountInfo([]byte(tt.input))
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseMountInfo() returned %d mounts, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("mount[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// ===================== readMountInfo =====================
+func TestReadMountInfo(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	ReadFile = func(path string) ([]byte, error) {
+		if path != "/proc/self/mountinfo" {
+			return nil, errors.New("unexpected path")
+		}
+		return os.ReadFile("testdata/proc/mountinfo")
+	}
+	mounts, err := readMountInfo()
+	if err != nil {
+		t.Fatalf("readMountInfo() unexpected error: %v", err)
+	}
+	if len(mounts) != 8 {
+		t.Errorf("readMountInfo() returned %d mounts, want 8", len(mounts))
+	}
+
+	ReadFile = func(string) ([]byte, error) { return nil, errors.New("permission denied") }
+	if _, err := readMountInfo(); err == nil {
+		t.Error("readMountInfo() expected error, got nil")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InterfaceAddrs is mockable in tests.
+var InterfaceAddrs = interfaceAddrs
+
+func interfaceAddrs(name string) ([]string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, a.String())
+	}
+	return out, nil
+}
+
+// NetCounters are the cumulative per-interface counters of /proc/net/dev.
+type NetCounters struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDrops   uint64 `json:"rx_drops"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDrops   uint64 `json:"tx_drops"`
+}
+
+// NetInterface describes one entry of /sys/class/net. SpeedMbps is -1 when
+// the link is down or the driver doesn't report it (virtual interfaces).
+type NetInterface struct {
+	Name      string      `json:"name"`
+	MAC       string      `json:"mac"`
+	MTU       int         `json:"mtu"`
+	OperState string      `json:"operstate"`
+	SpeedMbps int         `json:"speed_mbps"`
+	Duplex    string      `json:"duplex,omitempty"`
+	Driver    string      `json:"driver,omitempty"`
+	Addrs     []string    `json:"addrs,omitempty"`
+	Counters  NetCounters `json:"counters"`
+}
+
+// NetRate is the per-second change of NetCounters between two samples.
+type NetRate struct {
+	RxBytesPerSec   float64 `json:"rx_bytes_per_sec"`
+	TxBytesPerSec   float64 `json:"tx_bytes_per_sec"`
+	RxPacketsPerSec float64 `json:"rx_packets_per_sec"`
+	TxPacketsPerSec float64 `json:"tx_packets_per_sec"`
+}
+
+// NetSample is a timestamped set of counters, kept between main loop
+// iterations to compute rates.
+type NetSample struct {
+	Time     time.Time
+	Counters map[string]NetCounters
+}
+
+// parseProcNetDev parses /proc/net/dev. The first two lines are headers.
+func parseProcNetDev(data []byte) (map[string]NetCounters, error) {
+	counters := make(map[string]NetCounters)
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("/proc/net/dev output is not defined as expected - missing header")
+	}
+	for _, line := range lines[2:] {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			return nil, fmt.Errorf("short /proc/net/dev line %q", line)
+		}
+		var v [16]uint64
+		for i := range v {
+			n, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %q: %w", line, err)
+			}
+			v[i] = n
+		}
+		// Receive: bytes packets errs drop fifo frame compressed multicast,
+		// then the same eight columns for transmit.
+		counters[strings.TrimSpace(name)] = NetCounters{
+			RxBytes: v[0], RxPackets: v[1], RxErrors: v[2], RxDrops: v[3],
+			TxBytes: v[8], TxPackets: v[9], TxErrors: v[10], TxDrops: v[11],
+		}
+	}
+	return counters, nil
+}
+
+// readNetInterfaces walks <sysRoot>/class/net and attaches /proc/net/dev
+// counters and addresses to each interface.
+func readNetInterfaces(sysRoot string) ([]NetInterface, error) {
+	base := filepath.Join(sysRoot, "class/net")
+	names := readDirNames(base)
+	if names == nil {
+		return nil, fmt.Errorf("no interfaces found under %s", base)
+	}
+
+	var counters map[string]NetCounters
+	if data, err := ReadFile("/proc/net/dev"); err != nil {
+		fmt.Println("Reading /proc/net/dev failed:", err)
+	} else if counters, err = parseProcNetDev(data); err != nil {
+		fmt.Println("Parsing /proc/net/dev failed:", err)
+	}
+
+	ifaces := make([]NetInterface, 0, len(names))
+	for _, name := range names {
+		dir := filepath.Join(base, name)
+		iface := NetInterface{
+			Name:      name,
+			MAC:       readSysfsString(filepath.Join(dir, "address")),
+			OperState: readSysfsString(filepath.Join(dir, "operstate")),
+			SpeedMbps: -1,
+			Duplex:    readSysfsString(filepath.Join(dir, "duplex")),
+			Driver:    readSysfsLink(filepath.Join(dir, "device/driver")),
+			Counters:  counters[name],
+		}
+		iface.MTU, _ = strconv.Atoi(readSysfsString(filepath.Join(dir, "mtu")))
+		// speed reads fail with EINVAL on down links; the kernel also reports -1.
+		if speed, err := strconv.Atoi(readSysfsString(filepath.Join(dir, "speed"))); err == nil && speed > 0 {
+			iface.SpeedMbps = speed
+		}
+		if addrs, err := InterfaceAddrs(name); err == nil {
+			iface.Addrs = addrs
+		}
+		ifaces = append(ifaces, iface)
+	}
+	sort.Slice(ifaces, func(i, j int) bool { return ifaces[i].Name < ifaces[j].Name })
+	return ifaces, nil
+}
+
+// netRates returns per-second rates for interfaces present in both samples.
+// Counter resets (interface recreated) yield no rate for that interface.
+func netRates(prev, cur NetSample) map[string]NetRate {
+	rates := make(map[string]NetRate)
+	elapsed := cur.Time.Sub(prev.Time).Seconds()
+	if elapsed <= 0 {
+		return rates
+	}
+	for name, c := range cur.Counters {
+		p, ok := prev.Counters[name]
+		if !ok || c.RxBytes < p.RxBytes || c.TxBytes < p.TxBytes || c.RxPackets < p.RxPackets || c.TxPackets < p.TxPackets {
+			continue
+		}
+		rates[name] = NetRate{
+			RxBytesPerSec:   float64(c.RxBytes-p.RxBytes) / elapsed,
+			TxBytesPerSec:   float64(c.TxBytes-p.TxBytes) / elapsed,
+			RxPacketsPerSec: float64(c.RxPackets-p.RxPackets) / elapsed,
+			TxPacketsPerSec: float64(c.TxPackets-p.TxPackets) / elapsed,
+		}
+	}
+	return rates
+}
+
+// formatNetInterface renders an interface; rate is nil on the first iteration.
+func formatNetInterface(iface NetInterface, rate *NetRate) string {
+	speed := "unknown"
+	if iface.SpeedMbps > 0 {
+		speed = fmt.Sprintf("%dMb/s", iface.SpeedMbps)
+		if iface.Duplex != "" {
+			speed += " " + iface.Duplex
+		}
+	}
+	line := fmt.Sprintf("%s %s mac=%s mtu=%d speed=%s", iface.Name, iface.OperState, iface.MAC, iface.MTU, speed)
+	if iface.Driver != "" {
+		line += " driver=" + iface.Driver
+	}
+	if len(iface.Addrs) > 0 {
+		line += " addrs=" + strings.Join(iface.Addrs, ",")
+	}
+	c := iface.Counters
+	line += fmt.Sprintf("\n  rx %d bytes %d packets %d errors %d drops; tx %d bytes %d packets %d errors %d drops",
+		c.RxBytes, c.RxPackets, c.RxErrors, c.RxDrops, c.TxBytes, c.TxPackets, c.TxErrors, c.TxDrops)
+	if rate != nil {
+		line += fmt.Sprintf("\n  rate rx %s/s %.1f pkt/s; tx %s/s %.1f pkt/s",
+			formatBytes(uint64(rate.RxBytesPerSec)), rate.RxPacketsPerSec,
+			formatBytes(uint64(rate.TxBytesPerSec)), rate.TxPacketsPerSec)
+	}
+	return line
+}
+
+// readNetwork lists interfaces under sysRoot with rates relative to prev,
+// and returns the sample to pass as prev on the next iteration.
+func readNetwork(sysRoot string, prev NetSample) (string, NetSample) {
+	ifaces, err := readNetInterfaces(sysRoot)
+	if err != nil {
+		return "Reading network interfaces failed: " + err.Error(), prev
+	}
+	cur := NetSample{Time: time.Now(), Counters: make(map[string]NetCounters, len(ifaces))}
+	for _, iface := range ifaces {
+		cur.Counters[iface.Name] = iface.Counters
+	}
+	rates := netRates(prev, cur)
+
+	lines := make([]string, 0, len(ifaces))
+	for _, iface := range ifaces {
+		var rate *NetRate
+		if r, ok := rates[iface.Name]; ok {
+			rate = &r
+		}
+		lines = append(lines, formatNetInterface(iface, rate))
+	}
+	return strings.Join(lines, "\n"), cur
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================== parseProcNetDev =====================
+func TestParseProcNetDev(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/proc/net_dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]NetCounters
+		wantErr bool
+		success bool
+	}{
+		{
+			name:  "success: lo and eth0",
+			input: string(fixture),
+			want: map[string]NetCounters{
+				"lo":   {RxBytes: 123456, RxPackets: 1000, TxBytes: 123456, TxPackets: 1000},
+				"eth0": {RxBytes: 987654321, RxPackets: 654321, RxErrors: 2, RxDrops: 5, TxBytes: 123456789, TxPackets: 321000, TxErrors: 1, TxDrops: 3},
+			},
+			success: true,
+		},
+		{
+			name:    "success: headers only",
+			input:   "Inter-| Receive | Transmit\n face |bytes packets|bytes packets\n",
+			want:    map[string]NetCounters{},
+			success: true,
+		},
+		{
+			name:    "failure: short line",
+			input:   "h1\nh2\n  eth0: 1 2 3\n",
+			wantErr: true,
+			success: false,
+		},
+		{
+			name:    "failure: bad number",
+			input:   "h1\nh2\n  eth0: x 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n",
+			wantErr: true,
+			success: false,
+		},
+		{
+			name:    "failure: missing header",
+			input:   "",
+			wantErr: true,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProcNetDev([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProcNetDev() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseProcNetDev() = %+v, want %+v", got, tt.want)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("parseProcNetDev()[%s] = %+v, want %+v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+// ===================== netRates =====================
+func TestNetRates(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := NetSample{Time: start, Counters: map[string]NetCounters{
+		"eth0": {RxBytes: 1000, TxBytes: 2000, RxPackets: 10, TxPackets: 20},
+		"veth": {RxBytes: 5000},
+	}}
+	cur := NetSample{Time: start.Add(2 * time.Second), Counters: map[string]NetCounters{
+		"eth0": {RxBytes: 3000, TxBytes: 2400, RxPackets: 30, TxPackets: 24},
+		"veth": {RxBytes: 100}, // recreated, counters reset
+		"new0": {RxBytes: 100},
+	}}
+
+	rates := netRates(prev, cur)
+	want := NetRate{RxBytesPerSec: 1000, TxBytesPerSec: 200, RxPacketsPerSec: 10, TxPacketsPerSec: 2}
+	if got := rates["eth0"]; got != want {
+		t.Errorf("netRates()[eth0] = %+v, want %+v", got, want)
+	}
+	if len(rates) != 1 {
+		t.Errorf("netRates() = %+v, want only eth0", rates)
+	}
+	if got := netRates(cur, cur); len(got) != 0 {
+		t.Errorf("netRates(same, same) = %+v, want none", got)
+	}
+	if got := netRates(NetSample{}, cur); len(got) != 0 {
+		t.Errorf("netRates(empty, cur) = %+v, want none", got)
+	}
+}
+
+// ===================== readNetwork =====================
+func TestReadNetwork(t *testing.T) {
+	oldReadFile, oldAddrs := ReadFile, InterfaceAddrs
+	defer func() { ReadFile, InterfaceAddrs = oldReadFile, oldAddrs }()
+
+	InterfaceAddrs = func(name string) ([]string, error) {
+		if name == "eth0" {
+			return []string{"10.0.0.5/24", "fe80::5054:ff:fe12:3456/64"}, nil
+		}
+		return nil, errors.New("no such network interface")
+	}
+
+	tests := []struct {
+		name        string
+		sysRoot     string
+		netDevErr   error
+		prev        NetSample
+		wantContain []string
+		wantMissing []string
+		success     bool
+	}{
+		{
+			name:    "success: first iteration has no rates",
+			sysRoot: "testdata/sys",
+			wantContain: []string{
+				"eth0 up mac=52:54:00:12:34:56 mtu=1500 speed=10000Mb/s full driver=ixgbe addrs=10.0.0.5/24,fe80::5054:ff:fe12:3456/64",
+				"rx 987654321 bytes 654321 packets 2 errors 5 drops; tx 123456789 bytes 321000 packets 1 errors 3 drops",
+				"lo unknown mac=00:00:00:00:00:00 mtu=65536 speed=unknown\n",
+			},
+			wantMissing: []string{"rate"},
+			success:     true,
+		},
+		{
+			name:    "success: second iteration reports rates",
+			sysRoot: "testdata/sys",
+			prev: NetSample{Time: time.Now().Add(-time.Hour), Counters: map[string]NetCounters{
+				"eth0": {RxBytes: 987654321 - 3600*2048, TxBytes: 123456789, RxPackets: 654321, TxPackets: 321000},
+			}},
+			wantContain: []string{"rate rx 2.00 KiB/s"},
+			success:     true,
+		},
+		{
+			name:        "failure: /proc/net/dev unreadable still lists interfaces",
+			sysRoot:     "testdata/sys",
+			netDevErr:   errors.New("permission denied"),
+			wantContain: []string{"eth0 up", "rx 0 bytes"},
+			success:     false,
+		},
+		{
+			name:        "failure: sysfs missing",
+			sysRoot:     "testdata/does-not-exist",
+			wantContain: []string{"Reading network interfaces failed"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if path == "/proc/net/dev" {
+					if tt.netDevErr != nil {
+						return nil, tt.netDevErr
+					}
+					return os.ReadFile("testdata/proc/net_dev")
+				}
+				return os.ReadFile(path)
+			}
+			got, sample := readNetwork(tt.sysRoot, tt.prev)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("readNetwork() = %q, want to contain %q", got, want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(got, missing) {
+					t.Errorf("readNetwork() = %q, want no %q", got, missing)
+				}
+			}
+			if tt.sysRoot == "testdata/sys" && len(sample.Counters) != 2 {
+				t.Errorf("readNetwork() sample = %+v, want counters for eth0 and lo", sample.Counters)
+			}
+		})
+	}
+}
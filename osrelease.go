@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// osReleasePaths are tried in order by readOSRelease. /etc/lsb-release uses
+// DISTRIB_* keys and is only consulted when neither os-release file exists.
+var osReleasePaths = []string{
+	"/etc/os-release",
+	"/usr/lib/os-release",
+	"/etc/lsb-release",
+}
+
+// OSRelease holds the identification fields of os-release(5) that we use.
+type OSRelease struct {
+	Name            string   `json:"name"`
+	ID              string   `json:"id"`
+	IDLike          []string `json:"id_like,omitempty"`
+	Version         string   `json:"version,omitempty"`
+	VersionID       string   `json:"version_id,omitempty"`
+	VersionCodename string   `json:"version_codename,omitempty"`
+	Variant         string   `json:"variant,omitempty"`
+	VariantID       string   `json:"variant_id,omitempty"`
+	PrettyName      string   `json:"pretty_name"`
+}
+
+// Is reports whether the release is the given distro ID or declares it in ID_LIKE.
+func (r OSRelease) Is(id string) bool {
+	if r.ID == id {
+		return true
+	}
+	for _, like := range r.IDLike {
+		if like == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOSReleaseVars parses KEY=VALUE assignments as described in os-release(5):
+// blank lines and lines starting with '#' are ignored, values may be single- or
+// double-quoted and use shell-style backslash escapes. Malformed lines are skipped.
+func parseOSReleaseVars(data []byte) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok || !isOSReleaseKey(key) {
+			continue
+		}
+		val, err := unquoteShellValue(raw)
+		if err != nil {
+			fmt.Printf("Skipping malformed os-release line %q: %v\n", line, err)
+			continue
+		}
+		vars[key] = val
+	}
+	return vars
+}
+
+func isOSReleaseKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// unquoteShellValue expands the subset of shell quoting allowed by os-release(5).
+func unquoteShellValue(raw string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch c {
+		case '\'':
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 {
+				return "", errors.New("unterminated single quote")
+			}
+			b.WriteString(raw[i+1 : i+1+end])
+			i += end + 1
+		case '"':
+			i++
+			for ; i < len(raw) && raw[i] != '"'; i++ {
+				if raw[i] == '\\' && i+1 < len(raw) && strings.IndexByte("\"\\$`", raw[i+1]) >= 0 {
+					i++
+				}
+				b.WriteByte(raw[i])
+			}
+			if i >= len(raw) {
+				return "", errors.New("unterminated double quote")
+			}
+		case '\\':
+			if i+1 < len(raw) {
+				i++
+				b.WriteByte(raw[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+// osReleaseFromData builds an OSRelease from os-release content, applying the
+// defaults from os-release(5) for NAME, ID and PRETTY_NAME.
+func osReleaseFromData(data []byte) OSRelease {
+	vars := parseOSReleaseVars(data)
+	r := OSRelease{
+		Name:            vars["NAME"],
+		ID:              vars["ID"],
+		Version:         vars["VERSION"],
+		VersionID:       vars["VERSION_ID"],
+		VersionCodename: vars["VERSION_CODENAME"],
+		Variant:         vars["VARIANT"],
+		VariantID:       vars["VARIANT_ID"],
+		PrettyName:      vars["PRETTY_NAME"],
+	}
+	if like := strings.Fields(vars["ID_LIKE"]); len(like) > 0 {
+		r.IDLike = like
+	}
+	if r.Name == "" {
+		r.Name = "Linux"
+	}
+	if r.ID == "" {
+		r.ID = "linux"
+	}
+	if _, ok := vars["PRETTY_NAME"]; !ok {
+		r.PrettyName = "Linux"
+	}
+	return r
+}
+
+// lsbReleaseFromData maps /etc/lsb-release DISTRIB_* keys onto OSRelease.
+func lsbReleaseFromData(data []byte) OSRelease {
+	vars := parseOSReleaseVars(data)
+	r := OSRelease{
+		Name:            vars["DISTRIB_ID"],
+		ID:              strings.ToLower(vars["DISTRIB_ID"]),
+		VersionID:       vars["DISTRIB_RELEASE"],
+		VersionCodename: vars["DISTRIB_CODENAME"],
+		PrettyName:      vars["DISTRIB_DESCRIPTION"],
+	}
+	if r.Name == "" {
+		r.Name = "Linux"
+	}
+	if r.ID == "" {
+		r.ID = "linux"
+	}
+	if r.PrettyName == "" {
+		r.PrettyName = strings.TrimSpace(r.Name + " " + r.VersionID)
+	}
+	return r
+}
+
+// readOSRelease returns the first readable release file from osReleasePaths,
+// resolved under root (see hostPath). The returned error lists every path tried.
+func readOSRelease(root string) (OSRelease, error) {
+	var failures []string
+	for _, path := range osReleasePaths {
+		full := hostPath(root, path)
+		data, err := ReadFile(full)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", full, err))
+			continue
+		}
+		if strings.HasSuffix(path, "lsb-release") {
+			return lsbReleaseFromData(data), nil
+		}
+		return osReleaseFromData(data), nil
+	}
+	return OSRelease{}, errors.New(strings.Join(failures, "; "))
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================== parseOSReleaseVars =====================
+func TestParseOSReleaseVars(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		success bool
+	}{
+		{
+			name:    "success: double quotes with escapes",
+			input:   `NAME="Foo \"Bar\" \$HOME \\ \` + "`" + `x\` + "`" + `"`,
+			want:    map[string]string{"NAME": "Foo \"Bar\" $HOME \\ `x`"},
+			success: true,
+		},
+		{
+			name:    "success: single quotes are literal",
+			input:   `NAME='Foo \"Bar\"'`,
+			want:    map[string]string{"NAME": `Foo \"Bar\"`},
+			success: true,
+		},
+		{
+			name:    "success: unquoted value",
+			input:   "ID=debian",
+			want:    map[string]string{"ID": "debian"},
+			success: true,
+		},
+		{
+			name:    "success: comments and blank lines skipped",
+			input:   "# comment\n\n  ID=alpine  \n#ID=other\n",
+			want:    map[string]string{"ID": "alpine"},
+			success: true,
+		},
+		{
+			name:    "success: empty value",
+			input:   "VARIANT=",
+			want:    map[string]string{"VARIANT": ""},
+			success: true,
+		},
+		{
+			name:    "failure: unterminated double quote skipped",
+			input:   "NAME=\"Foo\nID=rhel",
+			want:    map[string]string{"ID": "rhel"},
+			success: false,
+		},
+		{
+			name:    "failure: unterminated single quote skipped",
+			input:   "NAME='Foo\nID=rhel",
+			want:    map[string]string{"ID": "rhel"},
+			success: false,
+		},
+		{
+			name:    "failure: invalid keys skipped",
+			input:   "lower=x\nNO EQUALS\n=value\nID=fedora",
+			want:    map[string]string{"ID": "fedora"},
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseOSReleaseVars([]byte(tt.input))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOSReleaseVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== osReleaseFromData =====================
+func TestOSReleaseFromData(t *testing.T) {
+	debian := `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+NAME="Debian GNU/Linux"
+VERSION_ID="12"
+VERSION="12 (bookworm)"
+VERSION_CODENAME=bookworm
+ID=debian
+HOME_URL="https://www.debian.org/"
+`
+	rhel := `NAME="Red Hat Enterprise Linux"
+VERSION="9.3 (Plow)"
+ID="rhel"
+ID_LIKE="fedora"
+VERSION_ID="9.3"
+PRETTY_NAME="Red Hat Enterprise Linux 9.3 (Plow)"
+VARIANT="Server"
+VARIANT_ID="server"
+`
+	alpine := `NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.19.1
+HOME_URL="https://alpinelinux.org/"
+`
+
+	tests := []struct {
+		name    string
+		input   string
+		want    OSRelease
+		success bool
+	}{
+		{
+			name:  "success: debian puts PRETTY_NAME first",
+			input: debian,
+			want: OSRelease{
+				Name: "Debian GNU/Linux", ID: "debian", Version: "12 (bookworm)", VersionID: "12",
+				VersionCodename: "bookworm", PrettyName: "Debian GNU/Linux 12 (bookworm)",
+			},
+			success: true,
+		},
+		{
+			name:  "success: rhel with ID_LIKE and VARIANT",
+			input: rhel,
+			want: OSRelease{
+				Name: "Red Hat Enterprise Linux", ID: "rhel", IDLike: []string{"fedora"},
+				Version: "9.3 (Plow)", VersionID: "9.3", Variant: "Server", VariantID: "server",
+				PrettyName: "Red Hat Enterprise Linux 9.3 (Plow)",
+			},
+			success: true,
+		},
+		{
+			name:  "success: alpine without PRETTY_NAME defaults to Linux",
+			input: alpine,
+			want: OSRelease{
+				Name: "Alpine Linux", ID: "alpine", VersionID: "3.19.1", PrettyName: "Linux",
+			},
+			success: true,
+		},
+		{
+			name:    "failure: empty input uses spec defaults",
+			input:   "",
+			want:    OSRelease{Name: "Linux", ID: "linux", PrettyName: "Linux"},
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := osReleaseFromData([]byte(tt.input))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("osReleaseFromData() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOSReleaseIs(t *testing.T) {
+	r := OSRelease{ID: "ubuntu", IDLike: []string{"debian"}}
+	if !r.Is("ubuntu") || !r.Is("debian") {
+		t.Errorf("Is() = false for own ID or ID_LIKE entry")
+	}
+	if r.Is("fedora") {
+		t.Errorf("Is(fedora) = true, want false")
+	}
+}
+
+// ===================== lsbReleaseFromData =====================
+func TestLSBReleaseFromData(t *testing.T) {
+	input := "DISTRIB_ID=Ubuntu\nDISTRIB_RELEASE=20.04\nDISTRIB_CODENAME=focal\nDISTRIB_DESCRIPTION=\"Ubuntu 20.04.6 LTS\"\n"
+	want := OSRelease{
+		Name: "Ubuntu", ID: "ubuntu", VersionID: "20.04", VersionCodename: "focal",
+		PrettyName: "Ubuntu 20.04.6 LTS",
+	}
+	if got := lsbReleaseFromData([]byte(input)); !reflect.DeepEqual(got, want) {
+		t.Errorf("lsbReleaseFromData() = %+v, want %+v", got, want)
+	}
+}
+
+// ===================== readOSRelease =====================
+func TestReadOSRelease(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantID   string
+		wantErr  bool
+		errParts []string
+		success  bool
+	}{
+		{
+			name:    "success: /etc/os-release preferred",
+			files:   map[string]string{"/etc/os-release": "ID=debian", "/usr/lib/os-release": "ID=other"},
+			wantID:  "debian",
+			success: true,
+		},
+		{
+			name:    "success: falls back to /usr/lib/os-release",
+			files:   map[string]string{"/usr/lib/os-release": "ID=rhel"},
+			wantID:  "rhel",
+			success: true,
+		},
+		{
+			name:    "success: falls back to /etc/lsb-release",
+			files:   map[string]string{"/etc/lsb-release": "DISTRIB_ID=Ubuntu"},
+			wantID:  "ubuntu",
+			success: true,
+		},
+		{
+			name:     "failure: no release files",
+			files:    map[string]string{},
+			wantErr:  true,
+			errParts: osReleasePaths,
+			success:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				data, ok := tt.files[path]
+				if !ok {
+					return nil, errors.New("no such file")
+				}
+				return []byte(data), nil
+			}
+			got, err := readOSRelease("")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("readOSRelease() expected error, got nil")
+				}
+				for _, part := range tt.errParts {
+					if !strings.Contains(err.Error(), part) {
+						t.Errorf("readOSRelease() error = %v, want to mention %q", err, part)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readOSRelease() unexpected error: %v", err)
+			}
+			if got.ID != tt.wantID {
+				t.Errorf("readOSRelease().ID = %q, want %q", got.ID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// pciIDsPaths are the usual locations of the pci.ids database (pciutils/hwdata).
+var pciIDsPaths = []string{
+	"/usr/share/misc/pci.ids",
+	"/usr/share/hwdata/pci.ids",
+	"/usr/share/pci.ids",
+}
+
+// PCIDevice describes one function under /sys/bus/pci/devices. IDs are
+// lower-case hex without the 0x prefix, Class is the 6-digit class code.
+type PCIDevice struct {
+	Address           string `json:"address"`
+	VendorID          string `json:"vendor_id"`
+	DeviceID          string `json:"device_id"`
+	SubsystemVendorID string `json:"subsystem_vendor_id,omitempty"`
+	SubsystemDeviceID string `json:"subsystem_device_id,omitempty"`
+	Class             string `json:"class"`
+	Driver            string `json:"driver,omitempty"`
+	NUMANode          int    `json:"numa_node"`
+	IOMMUGroup        string `json:"iommu_group,omitempty"`
+	LinkSpeed         string `json:"link_speed,omitempty"`
+	LinkWidth         string `json:"link_width,omitempty"`
+
+	VendorName    string `json:"vendor_name,omitempty"`
+	DeviceName    string `json:"device_name,omitempty"`
+	SubsystemName string `json:"subsystem_name,omitempty"`
+	ClassName     string `json:"class_name,omitempty"`
+}
+
+// readSysfsString returns the trimmed content of a sysfs attribute, or "" if
+// the attribute is missing or unreadable.
+func readSysfsString(path string) string {
+	data, err := ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readSysfsHex reads a "0x..." sysfs attribute and returns it without the prefix.
+func readSysfsHex(path string) string {
+	return strings.TrimPrefix(strings.ToLower(readSysfsString(path)), "0x")
+}
+
+// readSysfsLink returns the base name of a sysfs symlink target, e.g. the
+// driver name for ".../driver".
+func readSysfsLink(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// readPCIDevices walks <sysRoot>/bus/pci/devices and returns the devices
+// sorted by address.
+func readPCIDevices(sysRoot string) ([]PCIDevice, error) {
+	base := filepath.Join(sysRoot, "bus/pci/devices")
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]PCIDevice, 0, len(entries))
+	for _, e := range entries {
+		dir := filepath.Join(base, e.Name())
+		dev := PCIDevice{
+			Address:           e.Name(),
+			VendorID:          readSysfsHex(filepath.Join(dir, "vendor")),
+			DeviceID:          readSysfsHex(filepath.Join(dir, "device")),
+			SubsystemVendorID: readSysfsHex(filepath.Join(dir, "subsystem_vendor")),
+			SubsystemDeviceID: readSysfsHex(filepath.Join(dir, "subsystem_device")),
+			Class:             readSysfsHex(filepath.Join(dir, "class")),
+			Driver:            readSysfsLink(filepath.Join(dir, "driver")),
+			NUMANode:          -1,
+			IOMMUGroup:        readSysfsLink(filepath.Join(dir, "iommu_group")),
+			LinkSpeed:         readSysfsString(filepath.Join(dir, "current_link_speed")),
+			LinkWidth:         readSysfsString(filepath.Join(dir, "current_link_width")),
+		}
+		if n, err := strconv.Atoi(readSysfsString(filepath.Join(dir, "numa_node"))); err == nil {
+			dev.NUMANode = n
+		}
+		devices = append(devices, dev)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices, nil
+}
+
+// pciIDs is a parsed pci.ids database. Keys are lower-case hex joined with
+// ':' — "vendor", "vendor:device", "vendor:device:subvendor:subdevice" for
+// devices and "cc", "ccss", "ccsspp" for classes.
+type pciIDs struct {
+	names   map[string]string
+	classes map[string]string
+}
+
+// parsePCIIDs parses the pci.ids format: vendors at column 0, devices indented
+// by one tab, subsystems by two; the class section starts with "C cc  name".
+func parsePCIIDs(data []byte) *pciIDs {
+	db := &pciIDs{names: make(map[string]string), classes: make(map[string]string)}
+	var vendor, device, class, subclass string
+	inClasses := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		depth := len(line) - len(strings.TrimLeft(line, "\t"))
+		fields := strings.TrimSpace(line)
+
+		if depth == 0 {
+			if strings.HasPrefix(fields, "C ") {
+				inClasses = true
+				id, name := splitPCIIDsLine(strings.TrimPrefix(fields, "C "))
+				class = id
+				db.classes[class] = name
+				continue
+			}
+			inClasses = false
+			id, name := splitPCIIDsLine(fields)
+			vendor = id
+			db.names[vendor] = name
+			continue
+		}
+
+		if inClasses {
+			id, name := splitPCIIDsLine(fields)
+			switch depth {
+			case 1:
+				subclass = class + id
+				db.classes[subclass] = name
+			case 2:
+				db.classes[subclass+id] = name
+			}
+			continue
+		}
+
+		switch depth {
+		case 1:
+			id, name := splitPCIIDsLine(fields)
+			device = vendor + ":" + id
+			db.names[device] = name
+		case 2:
+			parts := strings.Fields(fields)
+			if len(parts) < 3 {
+				continue
+			}
+			_, name := splitPCIIDsLine(strings.Join(parts[1:], " "))
+			db.names[device+":"+strings.ToLower(parts[0])+":"+strings.ToLower(parts[1])] = name
+		}
+	}
+	return db
+}
+
+func splitPCIIDsLine(s string) (id, name string) {
+	id, name, _ = strings.Cut(s, " ")
+	return strings.ToLower(id), strings.TrimSpace(name)
+}
+
+// pciIDsCache holds the databases parsed so far by path, since pci.ids is
+// over a megabyte and the devices job runs on every iteration.
+var (
+	pciIDsMu    sync.Mutex
+	pciIDsCache = map[string]*pciIDs{}
+)
+
+// loadPCIIDs returns the first readable database from pciIDsPaths, or nil.
+func loadPCIIDs() *pciIDs {
+	pciIDsMu.Lock()
+	defer pciIDsMu.Unlock()
+	for _, path := range pciIDsPaths {
+		if db, ok := pciIDsCache[path]; ok {
+			return db
+		}
+		if data, err := ReadFile(path); err == nil {
+			db := parsePCIIDs(data)
+			pciIDsCache[path] = db
+			return db
+		}
+	}
+	return nil
+}
+
+// resolve fills in the human-readable names of dev. Unknown IDs stay empty.
+func (db *pciIDs) resolve(dev *PCIDevice) {
+	if db == nil {
+		return
+	}
+	dev.VendorName = db.names[dev.VendorID]
+	dev.DeviceName = db.names[dev.VendorID+":"+dev.DeviceID]
+	dev.SubsystemName = db.names[dev.VendorID+":"+dev.DeviceID+":"+dev.SubsystemVendorID+":"+dev.SubsystemDeviceID]
+	for _, n := range []int{6, 4, 2} {
+		if len(dev.Class) >= n {
+			if name, ok := db.classes[dev.Class[:n]]; ok {
+				dev.ClassName = name
+				break
+			}
+		}
+	}
+}
+
+// formatPCIDevice renders a device as one lspci-like line.
+func formatPCIDevice(dev PCIDevice) string {
+	class := dev.ClassName
+	if class == "" {
+		class = "Class"
+	}
+	name := strings.TrimSpace(dev.VendorName + " " + dev.DeviceName)
+	if name == "" {
+		name = "Device"
+	}
+	line := fmt.Sprintf("%s %s [%s]: %s [%s:%s]", dev.Address, class, dev.Class, name, dev.VendorID, dev.DeviceID)
+	if dev.Driver != "" {
+		line += " driver=" + dev.Driver
+	}
+	line += fmt.Sprintf(" numa=%d", dev.NUMANode)
+	if dev.IOMMUGroup != "" {
+		line += " iommu_group=" + dev.IOMMUGroup
+	}
+	if dev.LinkSpeed != "" {
+		line += fmt.Sprintf(" link=%s x%s", dev.LinkSpeed, dev.LinkWidth)
+	}
+	return line
+}
+
+// readDevicesFrom lists PCI devices under sysRoot with names from pci.ids.
+func readDevicesFrom(sysRoot string) string {
+	devices, err := readPCIDevices(sysRoot)
+	if err != nil {
+		return "Reading PCI devices from sysfs failed: " + err.Error()
+	}
+	db := loadPCIIDs()
+	lines := make([]string, 0, len(devices))
+	for i := range devices {
+		db.resolve(&devices[i])
+		lines = append(lines, formatPCIDevice(devices[i]))
+	}
+	return strings.Join(lines, "\n")
+}
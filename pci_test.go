@@ -0,0 +1,170 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================== readPCIDevices (fake sysfs in testdata) =====================
+func TestReadPCIDevices(t *testing.T) {
+	devices, err := readPCIDevices("testdata/sys")
+	if err != nil {
+		t.Fatalf("readPCIDevices() unexpected error: %v", err)
+	}
+
+	want := []PCIDevice{
+		{Address: "0000:00:00.0", VendorID: "8086", DeviceID: "29c0", SubsystemVendorID: "1028", SubsystemDeviceID: "04b2", Class: "060000", NUMANode: -1},
+		{Address: "0000:00:1f.2", VendorID: "8086", DeviceID: "2922", SubsystemVendorID: "1028", SubsystemDeviceID: "04b2", Class: "010601", Driver: "ahci", NUMANode: -1, IOMMUGroup: "3"},
+		{Address: "0000:01:00.0", VendorID: "144d", DeviceID: "a808", SubsystemVendorID: "144d", SubsystemDeviceID: "a801", Class: "010802", Driver: "nvme", NUMANode: 0, IOMMUGroup: "12", LinkSpeed: "8.0 GT/s PCIe", LinkWidth: "4"},
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("readPCIDevices() returned %d devices, want %d", len(devices), len(want))
+	}
+	for i := range want {
+		if devices[i] != want[i] {
+			t.Errorf("device[%d] = %+v, want %+v", i, devices[i], want[i])
+		}
+	}
+}
+
+func TestReadPCIDevices_MissingSysfs(t *testing.T) {
+	if _, err := readPCIDevices("testdata/does-not-exist"); err == nil {
+		t.Error("readPCIDevices() expected error for missing sysfs, got nil")
+	}
+}
+
+// ===================== parsePCIIDs / resolve =====================
+func TestPCIIDsResolve(t *testing.T) {
+	oldPaths := pciIDsPaths
+	defer func() { pciIDsPaths = oldPaths }()
+
+	tests := []struct {
+		name          string
+		paths         []string
+		dev           PCIDevice
+		wantVendor    string
+		wantDevice    string
+		wantSubsystem string
+		wantClass     string
+		success       bool
+	}{
+		{
+			name:          "success: full match down to prog-if",
+			paths:         []string{"testdata/pci.ids"},
+			dev:           PCIDevice{VendorID: "8086", DeviceID: "2922", SubsystemVendorID: "1028", SubsystemDeviceID: "04b2", Class: "010601"},
+			wantVendor:    "Intel Corporation",
+			wantDevice:    "82801IR/IO/IH (ICH9R/DO/DH) 6 port SATA Controller [AHCI mode]",
+			wantSubsystem: "Vostro 220",
+			wantClass:     "AHCI 1.0",
+			success:       true,
+		},
+		{
+			name:       "success: class falls back to subclass",
+			paths:      []string{"testdata/pci.ids"},
+			dev:        PCIDevice{VendorID: "8086", DeviceID: "29c0", Class: "060000"},
+			wantVendor: "Intel Corporation",
+			wantDevice: "82G33/G31/P35/P31 Express DRAM Controller",
+			wantClass:  "Host bridge",
+			success:    true,
+		},
+		{
+			name:       "failure: unknown vendor leaves names empty",
+			paths:      []string{"testdata/pci.ids"},
+			dev:        PCIDevice{VendorID: "dead", DeviceID: "beef", Class: "ff0000"},
+			wantVendor: "",
+			success:    false,
+		},
+		{
+			name:    "failure: no pci.ids available",
+			paths:   []string{"testdata/missing.ids"},
+			dev:     PCIDevice{VendorID: "8086", DeviceID: "2922", Class: "010601"},
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pciIDsPaths = tt.paths
+			dev := tt.dev
+			loadPCIIDs().resolve(&dev)
+			if dev.VendorName != tt.wantVendor || dev.DeviceName != tt.wantDevice ||
+				dev.SubsystemName != tt.wantSubsystem || dev.ClassName != tt.wantClass {
+				t.Errorf("resolve() = vendor %q device %q subsystem %q class %q, want %q %q %q %q",
+					dev.VendorName, dev.DeviceName, dev.SubsystemName, dev.ClassName,
+					tt.wantVendor, tt.wantDevice, tt.wantSubsystem, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestLoadPCIIDs_ParsesOnce(t *testing.T) {
+	oldPaths, oldReadFile := pciIDsPaths, ReadFile
+	defer func() { pciIDsPaths, ReadFile = oldPaths, oldReadFile }()
+	pciIDsPaths = []string{filepath.Join(t.TempDir(), "pci.ids")}
+	reads := 0
+	ReadFile = func(path string) ([]byte, error) {
+		reads++
+		return []byte("8086  Intel Corporation\n"), nil
+	}
+
+	first, second := loadPCIIDs(), loadPCIIDs()
+	if reads != 1 || first != second {
+		t.Errorf("pci.ids read %d times, want once and the same parsed table reused", reads)
+	}
+	dev := PCIDevice{VendorID: "8086"}
+	second.resolve(&dev)
+	if dev.VendorName != "Intel Corporation" {
+		t.Errorf("VendorName = %q, want Intel Corporation", dev.VendorName)
+	}
+}
+
+// ===================== readDevicesFrom =====================
+func TestReadDevicesFrom(t *testing.T) {
+	oldPaths := pciIDsPaths
+	defer func() { pciIDsPaths = oldPaths }()
+	pciIDsPaths = []string{"testdata/pci.ids"}
+
+	tests := []struct {
+		name        string
+		sysRoot     string
+		wantContain []string
+		success     bool
+	}{
+		{
+			name:    "success: fake sysfs tree",
+			sysRoot: "testdata/sys",
+			wantContain: []string{
+				"0000:00:00.0 Host bridge [060000]: Intel Corporation 82G33/G31/P35/P31 Express DRAM Controller [8086:29c0] numa=-1",
+				"0000:00:1f.2 AHCI 1.0 [010601]: Intel Corporation 82801IR/IO/IH (ICH9R/DO/DH) 6 port SATA Controller [AHCI mode] [8086:2922] driver=ahci numa=-1 iommu_group=3",
+				"0000:01:00.0 NVM Express [010802]: Samsung Electronics Co Ltd NVMe SSD Controller SM981/PM981/PM983 [144d:a808] driver=nvme numa=0 iommu_group=12 link=8.0 GT/s PCIe x4",
+			},
+			success: true,
+		},
+		{
+			name:        "failure: sysfs missing",
+			sysRoot:     "testdata/does-not-exist",
+			wantContain: []string{"Reading PCI devices from sysfs failed"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readDevicesFrom(tt.sysRoot)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("readDevicesFrom() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatPCIDevice_Unresolved(t *testing.T) {
+	got := formatPCIDevice(PCIDevice{Address: "0000:02:00.0", VendorID: "dead", DeviceID: "beef", Class: "ff0000", NUMANode: -1})
+	want := "0000:02:00.0 Class [ff0000]: Device [dead:beef] numa=-1"
+	if got != want {
+		t.Errorf("formatPCIDevice() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// loopDevicePlaceholder stands in for the loop device in a dry run; the real
+// one is picked with losetup -f when the procedure starts.
+const loopDevicePlaceholder = "<free-loop-device>"
+
+// PlanStep is a step as a dry run shows it. Exec is the argv that would be
+// executed, after the shell wrapper and elevation are applied.
+type PlanStep struct {
+	Step
+	Exec []string `json:"exec"`
+}
+
+// Plan is everything a procedure would do, with templating resolved.
+type Plan struct {
+	Procedure    string       `json:"procedure"`
+	HomeDir      string       `json:"home_dir"`
+	TestFileSize string       `json:"test_file_size"`
+	LoopDevice   string       `json:"loop_device,omitempty"`
+	Elevation    string       `json:"elevation"`
+	Requirements Requirements `json:"requirements"`
+	Cleanup      []PlanStep   `json:"cleanup"`
+	Steps        []PlanStep   `json:"steps"`
+}
+
+func planSteps(steps []Step) []PlanStep {
+	out := make([]PlanStep, len(steps))
+	for i, step := range steps {
+		out[i] = PlanStep{Step: step, Exec: stepArgv(step)}
+	}
+	return out
+}
+
+// planProcedure builds the plan of the disk or LVM procedure without running
+// anything.
+func planProcedure(lvm bool) (Plan, error) {
+	homeDir, err := testHomeDir()
+	if err != nil {
+		return Plan{}, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	p := Plan{
+		Procedure:    "disk",
+		HomeDir:      homeDir,
+		TestFileSize: testFileSize,
+		Elevation:    elevation.Name,
+		Requirements: diskRequirements,
+		Cleanup:      []PlanStep{},
+		Steps:        planSteps(diskCommands(homeDir)),
+	}
+	if lvm {
+		p.Procedure = "LVM"
+		p.LoopDevice = loopDevicePlaceholder
+		p.Requirements = lvmRequirements
+		p.Cleanup = planSteps(lvmCleanupCommands(homeDir, loopDevicePlaceholder))
+		p.Steps = planSteps(lvmCommands(homeDir, loopDevicePlaceholder))
+	}
+	return p, nil
+}
+
+// formatPlan renders a plan as "text" or "json".
+func formatPlan(p Plan, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(p, "", "  ")
+		return string(data), err
+	case "text":
+	default:
+		return "", fmt.Errorf("unknown format %q (want text or json)", format)
+	}
+
+	caps := make([]string, len(p.Requirements.Capabilities))
+	for i, bit := range p.Requirements.Capabilities {
+		caps[i] = capName(bit)
+	}
+	lines := []string{
+		fmt.Sprintf("=== Dry run: %s Procedure ===", p.Procedure),
+		"Home directory: " + p.HomeDir,
+		"Test file size: " + p.TestFileSize,
+		"Elevation: " + p.Elevation,
+	}
+	if p.LoopDevice != "" {
+		lines = append(lines, fmt.Sprintf("Loop device: %s (picked with losetup -f at run time)", p.LoopDevice))
+	}
+	lines = append(lines,
+		"Preflight requirements:",
+		"  binaries: "+orNone(strings.Join(p.Requirements.Binaries, ", ")),
+		"  devices: "+orNone(strings.Join(p.Requirements.Devices, ", ")),
+		"  capabilities: "+orNone(strings.Join(caps, ", ")),
+		"  kernel modules: "+orNone(strings.Join(p.Requirements.Modules, ", ")),
+	)
+	if len(p.Cleanup) > 0 {
+		lines = append(lines, "Cleanup steps (errors ignored):")
+		for i, s := range p.Cleanup {
+			lines = append(lines, fmt.Sprintf("  %2d. %s", i+1, shellJoin(s.Exec)))
+		}
+	}
+	lines = append(lines, "Steps:")
+	for i, s := range p.Steps {
+		lines = append(lines, fmt.Sprintf("  %2d. %s", i+1, shellJoin(s.Exec)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ===================== planProcedure / formatPlan =====================
+func TestPlanProcedure(t *testing.T) {
+	oldRun, oldExec, oldElevation := RunArgv, ExecOutput, elevation
+	defer func() { RunArgv, ExecOutput, elevation = oldRun, oldExec, oldElevation }()
+	RunArgv = func(argv []string, _ *Recorder) error {
+		t.Errorf("dry run executed %q", argv)
+		return nil
+	}
+	ExecOutput = func(name string, arg ...string) ([]byte, error) {
+		t.Errorf("dry run executed %s %q", name, arg)
+		return nil, errors.New("not allowed")
+	}
+
+	tests := []struct {
+		name        string
+		lvm         bool
+		elevation   string
+		home        string
+		wantSteps   int
+		wantCleanup int
+		wantContain []string
+		wantErr     bool
+		success     bool
+	}{
+		{
+			name:      "success: disk procedure as root",
+			elevation: "none",
+			home:      "/root",
+			wantSteps: 12,
+			wantContain: []string{
+				"=== Dry run: disk Procedure ===",
+				"Home directory: /root",
+				"  kernel modules: loop",
+				"  capabilities: CAP_SYS_ADMIN",
+				"   5. mount -o loop /root/file_systems_test/disk1 /mnt/disk1",
+			},
+			success: true,
+		},
+		{
+			name:        "success: LVM procedure through sudo with a quoted home dir",
+			lvm:         true,
+			elevation:   "sudo",
+			home:        "/home/with space",
+			wantSteps:   25,
+			wantCleanup: 7,
+			wantContain: []string{
+				"Loop device: <free-loop-device> (picked with losetup -f at run time)",
+				"Cleanup steps (errors ignored):\n   1. sudo -n umount /mnt/lvm1 /mnt/lvm2",
+				"   3. sudo -n losetup '<free-loop-device>' '/home/with space/file_systems_test/disk1'",
+				"  devices: /dev/loop-control, /dev/mapper/control",
+			},
+			success: true,
+		},
+		{
+			name:      "success: nsenter wraps every disk step so they share the node's filesystem",
+			elevation: "nsenter",
+			home:      "/root",
+			wantSteps: 12,
+			wantContain: []string{
+				"   1. nsenter --target 1 --mount -- mkdir -p /root/file_systems_test",
+				"   2. nsenter --target 1 --mount -- fallocate -l 100M /root/file_systems_test/disk1",
+				"   5. nsenter --target 1 --mount -- mount -o loop /root/file_systems_test/disk1 /mnt/disk1",
+				"  10. nsenter --target 1 --mount -- rm -f /root/file_systems_test/disk1",
+			},
+			success: true,
+		},
+		{
+			name:        "success: nsenter wraps every LVM step and cleanup step",
+			lvm:         true,
+			elevation:   "nsenter",
+			home:        "/root",
+			wantSteps:   25,
+			wantCleanup: 7,
+			wantContain: []string{
+				"   2. nsenter --target 1 --mount -- fallocate -l 100M /root/file_systems_test/disk1",
+				"   3. nsenter --target 1 --mount -- losetup '<free-loop-device>' /root/file_systems_test/disk1",
+				"  17. nsenter --target 1 --mount -- cat /mnt/lvm1/test.txt",
+			},
+			success: true,
+		},
+		{
+			name:    "failure: relative home dir",
+			home:    "relative",
+			wantErr: true,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", tt.home)
+			elevation = elevations[tt.elevation]
+			plan, err := planProcedure(tt.lvm)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("planProcedure() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planProcedure() unexpected error: %v", err)
+			}
+			if len(plan.Steps) != tt.wantSteps || len(plan.Cleanup) != tt.wantCleanup {
+				t.Errorf("plan has %d steps and %d cleanup steps, want %d and %d", len(plan.Steps), len(plan.Cleanup), tt.wantSteps, tt.wantCleanup)
+			}
+
+			text, err := formatPlan(plan, "text")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantContain {
+				if !strings.Contains(text, want) {
+					t.Errorf("formatPlan() = %q, want to contain %q", text, want)
+				}
+			}
+
+			data, err := formatPlan(plan, "json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded Plan
+			if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+				t.Fatalf("formatPlan(json) is not valid JSON: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, plan) {
+				t.Errorf("JSON round trip = %+v, want %+v", decoded, plan)
+			}
+		})
+	}
+}
+
+func TestFormatPlan_UnknownFormat(t *testing.T) {
+	if _, err := formatPlan(Plan{}, "yaml"); err == nil || !strings.Contains(err.Error(), `unknown format "yaml"`) {
+		t.Errorf("formatPlan(yaml) error = %v, want unknown format", err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// systemBinDirs are where a rule naming a binary without a path may find it.
+var systemBinDirs = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// PolicyRule allows one command shape. Binary is an absolute path, or a bare
+// name that must resolve into one of systemBinDirs. Args are regular
+// expressions matched against the arguments one by one; each must match the
+// whole argument and the argument count must equal len(Args).
+type PolicyRule struct {
+	Binary string   `json:"binary"`
+	Args   []string `json:"args"`
+
+	args []*regexp.Regexp
+}
+
+// Policy is the allowlist runCommand enforces: a step runs only when some
+// rule matches its argv (before elevation).
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyError is a step denied by the policy.
+type PolicyError struct {
+	Argv   []string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy denied %s: %s", shellJoin(e.Argv), e.Reason)
+}
+
+// policy is enforced by runCommand; nil allows everything. main installs
+// defaultPolicy or the file given with -policy.
+var policy *Policy
+
+// Patterns for the values procedures splice into their commands.
+const (
+	testDirPattern  = `/(.+/)?file_systems_test`
+	diskFilePattern = testDirPattern + `/disk1`
+	loopPattern     = `/dev/loop[0-9]+`
+	sizePattern     = `[0-9]+[KMGT]?(iB)?`
+)
+
+// shellRule allows exactly one bash -c script with arguments matching args.
+func shellRule(script string, args ...string) PolicyRule {
+	return PolicyRule{Binary: "bash", Args: append([]string{"-c", regexp.QuoteMeta(script), "bash"}, args...)}
+}
+
+// defaultPolicy allows the steps of the disk and LVM procedures and their
+// cleanup, and nothing else.
+func defaultPolicy() *Policy {
+	p := &Policy{Rules: []PolicyRule{
+		{Binary: "mkdir", Args: []string{"-p", testDirPattern}},
+		{Binary: "mkdir", Args: []string{"-p", "/mnt/disk1"}},
+		{Binary: "mkdir", Args: []string{"-p", "/mnt/lvm1", "/mnt/lvm2"}},
+		{Binary: "fallocate", Args: []string{"-l", sizePattern, diskFilePattern}},
+		{Binary: "mkfs.ext4", Args: []string{"-F", diskFilePattern}},
+		{Binary: "mkfs.ext4", Args: []string{"-F", "/dev/mapper/testvg-testlv[12]"}},
+		{Binary: "mount", Args: []string{"-o", "loop", diskFilePattern, "/mnt/disk1"}},
+		{Binary: "mount", Args: []string{"/dev/mapper/testvg-testlv1", "/mnt/lvm1"}},
+		{Binary: "mount", Args: []string{"/dev/mapper/testvg-testlv2", "/mnt/lvm2"}},
+		shellRule(`printf "Hello ext4\n" > "$1"`, `/mnt/disk1/test\.txt`),
+		shellRule(`printf "Hello LVM LV1\n" > "$1"`, `/mnt/lvm1/test\.txt`),
+		shellRule(`printf "Hello LVM LV2\n" > "$1"`, `/mnt/lvm2/test\.txt`),
+		{Binary: "cat", Args: []string{`/mnt/(disk1|lvm1|lvm2)/test\.txt`}},
+		{Binary: "umount", Args: []string{"/mnt/disk1"}},
+		{Binary: "umount", Args: []string{"/mnt/lvm1", "/mnt/lvm2"}},
+		{Binary: "wipefs", Args: []string{"-a", diskFilePattern}},
+		{Binary: "rm", Args: []string{"-f", diskFilePattern}},
+		{Binary: "rm", Args: []string{"-rf", "/mnt/disk1"}},
+		{Binary: "rm", Args: []string{"-rf", testDirPattern}},
+		{Binary: "rm", Args: []string{"-rf", "/mnt/lvm1", "/mnt/lvm2", testDirPattern}},
+		{Binary: "rm", Args: []string{"-rf", "/dev/testvg"}},
+		{Binary: "losetup", Args: []string{loopPattern, diskFilePattern}},
+		{Binary: "losetup", Args: []string{"-d", loopPattern}},
+		{Binary: "losetup", Args: []string{"-f"}},
+		{Binary: "pvcreate", Args: []string{"-y", loopPattern}},
+		{Binary: "pvremove", Args: []string{"-y", loopPattern}},
+		{Binary: "vgcreate", Args: []string{"testvg", loopPattern}},
+		{Binary: "lvcreate", Args: []string{"-Z", "n", "-l", "(50|100)%FREE", "-n", "testlv[12]", "testvg"}},
+		{Binary: "vgchange", Args: []string{"-ay", "testvg"}},
+		{Binary: "vgscan", Args: []string{"--mknodes"}},
+		{Binary: "lvremove", Args: []string{"-y", "testvg/testlv1", "testvg/testlv2"}},
+		{Binary: "vgremove", Args: []string{"-y", "testvg"}},
+		{Binary: "true", Args: []string{}}, // the preflight probe of sudo or doas
+	}}
+	if err := p.compile(); err != nil {
+		panic(err) // the built-in rules are constant
+	}
+	return p
+}
+
+// parsePolicy decodes and compiles a JSON policy file.
+func parsePolicy(data []byte) (*Policy, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var p Policy
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("decoding policy: %w", err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// loadPolicy reads the -policy file; an empty path selects defaultPolicy.
+func loadPolicy(path string) (*Policy, error) {
+	if path == "" {
+		return defaultPolicy(), nil
+	}
+	data, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePolicy(data)
+}
+
+func (p *Policy) compile() error {
+	if len(p.Rules) == 0 {
+		return fmt.Errorf("policy has no rules")
+	}
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		if r.Binary == "" || (strings.Contains(r.Binary, "/") && !filepath.IsAbs(r.Binary)) {
+			return fmt.Errorf("rule %d: binary %q must be an absolute path or a bare name", i+1, r.Binary)
+		}
+		r.args = make([]*regexp.Regexp, len(r.Args))
+		for j, pattern := range r.Args {
+			re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+			if err != nil {
+				return fmt.Errorf("rule %d (%s): argument %d: %w", i+1, r.Binary, j+1, err)
+			}
+			r.args[j] = re
+		}
+	}
+	return nil
+}
+
+// allowsBinary reports whether the rule names the resolved binary path.
+func (r PolicyRule) allowsBinary(path string) bool {
+	if filepath.IsAbs(r.Binary) {
+		return path == r.Binary
+	}
+	if filepath.Base(path) != r.Binary {
+		return false
+	}
+	for _, dir := range systemBinDirs {
+		if filepath.Dir(path) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PolicyRule) allowsArgs(args []string) bool {
+	if len(args) != len(r.args) {
+		return false
+	}
+	for i, re := range r.args {
+		if !re.MatchString(args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check returns a *PolicyError unless some rule allows argv. argv[0] is
+// resolved where the elevation runs steps, the same way exec.Command
+// resolves it.
+func (p *Policy) Check(argv []string) error {
+	if p == nil {
+		return nil
+	}
+	if len(argv) == 0 {
+		return &PolicyError{Argv: argv, Reason: "empty command"}
+	}
+	path, err := elevation.LookPath(argv[0])
+	if err != nil {
+		return &PolicyError{Argv: argv, Reason: err.Error()}
+	}
+	binaryAllowed := false
+	for _, r := range p.Rules {
+		if !r.allowsBinary(path) {
+			continue
+		}
+		binaryAllowed = true
+		if r.allowsArgs(argv[1:]) {
+			return nil
+		}
+	}
+	if !binaryAllowed {
+		return &PolicyError{Argv: argv, Reason: fmt.Sprintf("binary %s is not allowlisted", path)}
+	}
+	return &PolicyError{Argv: argv, Reason: fmt.Sprintf("arguments do not match any rule for %s", path)}
+}
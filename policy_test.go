@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================== defaultPolicy =====================
+func TestDefaultPolicy_AllowsProcedureSteps(t *testing.T) {
+	oldLookPath := LookPath
+	defer func() { LookPath = oldLookPath }()
+	LookPath = func(file string) (string, error) { return "/usr/sbin/" + file, nil }
+
+	p := defaultPolicy()
+	for _, home := range []string{"/root", "/", "/home/with space", "/home/x; rm -rf /"} {
+		var steps []Step
+		steps = append(steps, diskCommands(home)...)
+		steps = append(steps, lvmCommands(home, "/dev/loop12")...)
+		steps = append(steps, lvmCleanupCommands(home, "/dev/loop12")...)
+		for _, step := range steps {
+			if err := p.Check(commandArgv(step)); err != nil {
+				t.Errorf("home %q: %v", home, err)
+			}
+		}
+	}
+}
+
+// ===================== Policy.Check =====================
+func TestPolicyCheck(t *testing.T) {
+	oldLookPath := LookPath
+	defer func() { LookPath = oldLookPath }()
+
+	custom, err := parsePolicy([]byte(`{"rules": [{"binary": "/opt/tools/bin/probe", "args": ["--dev", "/dev/sd[a-z]"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		policy     *Policy
+		argv       []string
+		paths      map[string]string
+		wantReason string
+		success    bool
+	}{
+		{
+			name:    "success: nil policy allows everything",
+			argv:    []string{"curl", "http://example.com"},
+			success: true,
+		},
+		{
+			name:    "success: absolute binary rule",
+			policy:  custom,
+			argv:    []string{"probe", "--dev", "/dev/sdb"},
+			paths:   map[string]string{"probe": "/opt/tools/bin/probe"},
+			success: true,
+		},
+		{
+			name:       "failure: binary not in the allowlist",
+			policy:     defaultPolicy(),
+			argv:       []string{"curl", "http://example.com"},
+			wantReason: "binary /usr/bin/curl is not allowlisted",
+			success:    false,
+		},
+		{
+			name:       "failure: allowed binary with other arguments",
+			policy:     defaultPolicy(),
+			argv:       []string{"rm", "-rf", "/"},
+			wantReason: "arguments do not match any rule for /usr/bin/rm",
+			success:    false,
+		},
+		{
+			name:       "failure: extra argument smuggled after an allowed one",
+			policy:     defaultPolicy(),
+			argv:       []string{"umount", "/mnt/disk1", "/"},
+			wantReason: "arguments do not match any rule for /usr/bin/umount",
+			success:    false,
+		},
+		{
+			name:       "failure: patterns match whole arguments only",
+			policy:     defaultPolicy(),
+			argv:       []string{"losetup", "-d", "/dev/loop0 /dev/sda"},
+			wantReason: "arguments do not match any rule for /usr/bin/losetup",
+			success:    false,
+		},
+		{
+			name:       "failure: bare name resolving outside the system directories",
+			policy:     defaultPolicy(),
+			argv:       []string{"mount", "-o", "loop", "/root/file_systems_test/disk1", "/mnt/disk1"},
+			paths:      map[string]string{"mount": "/tmp/evil/mount"},
+			wantReason: "binary /tmp/evil/mount is not allowlisted",
+			success:    false,
+		},
+		{
+			name:       "failure: binary not found",
+			policy:     custom,
+			argv:       []string{"probe", "--dev", "/dev/sdb"},
+			paths:      map[string]string{"probe": ""},
+			wantReason: "executable file not found in $PATH",
+			success:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			LookPath = func(file string) (string, error) {
+				if path, ok := tt.paths[file]; ok {
+					if path == "" {
+						return "", errors.New("executable file not found in $PATH")
+					}
+					return path, nil
+				}
+				return "/usr/bin/" + file, nil
+			}
+			err := tt.policy.Check(tt.argv)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("Check() unexpected error: %v", err)
+				}
+				return
+			}
+			var denied *PolicyError
+			if !errors.As(err, &denied) || denied.Reason != tt.wantReason {
+				t.Errorf("Check() = %v, want policy error %q", err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestPolicyCheck_NsenterResolvesOnTheNode(t *testing.T) {
+	oldLookPath, oldElevation := LookPath, elevation
+	defer func() { LookPath, elevation = oldLookPath, oldElevation }()
+	// The container has every binary in /usr/sbin; the node differs.
+	LookPath = func(file string) (string, error) { return "/usr/sbin/" + file, nil }
+	root := t.TempDir()
+	t.Setenv("PATH", "/usr/local/bin:/usr/sbin")
+	os.MkdirAll(filepath.Join(root, "usr/local/bin"), 0o755)
+	os.MkdirAll(filepath.Join(root, "usr/sbin"), 0o755)
+	os.WriteFile(filepath.Join(root, "usr/sbin/losetup"), nil, 0o755)
+	os.WriteFile(filepath.Join(root, "usr/local/bin/mount"), nil, 0o755)
+	os.WriteFile(filepath.Join(root, "usr/sbin/mkfs.ext4"), nil, 0o644) // not executable
+	elevation = elevations["nsenter"]
+	elevation.Root = root
+
+	tests := []struct {
+		name       string
+		argv       []string
+		wantReason string
+		success    bool
+	}{
+		{name: "success: binary found on the node", argv: []string{"losetup", "-f"}, success: true},
+		{name: "success: binary in another allowed dir on the node", argv: []string{"mount", "/dev/mapper/testvg-testlv1", "/mnt/lvm1"}, success: true},
+		{name: "failure: binary only in the container", argv: []string{"wipefs", "-a", "/root/file_systems_test/disk1"}, wantReason: "not found in $PATH under " + root, success: false},
+		{name: "failure: non-executable file on the node", argv: []string{"mkfs.ext4", "-F", "/root/file_systems_test/disk1"}, wantReason: "not found in $PATH under " + root, success: false},
+	}
+
+	p := defaultPolicy()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Check(tt.argv)
+			var denied *PolicyError
+			if tt.success && err != nil {
+				t.Errorf("Check(%q) = %v, want allowed", tt.argv, err)
+			}
+			if !tt.success && (!errors.As(err, &denied) || !strings.Contains(denied.Reason, tt.wantReason)) {
+				t.Errorf("Check(%q) = %v, want denied with %q", tt.argv, err, tt.wantReason)
+			}
+		})
+	}
+}
+
+// ===================== parsePolicy =====================
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+		success bool
+	}{
+		{
+			name:    "success: rule with patterns",
+			input:   `{"rules": [{"binary": "mount", "args": ["-o", "loop", "/.+"]}]}`,
+			success: true,
+		},
+		{
+			name:    "failure: unknown field",
+			input:   `{"rules": [{"binary": "mount", "argv": []}]}`,
+			wantErr: `unknown field "argv"`,
+			success: false,
+		},
+		{
+			name:    "failure: relative binary path",
+			input:   `{"rules": [{"binary": "bin/mount", "args": []}]}`,
+			wantErr: `rule 1: binary "bin/mount" must be an absolute path or a bare name`,
+			success: false,
+		},
+		{
+			name:    "failure: invalid pattern",
+			input:   `{"rules": [{"binary": "mount", "args": ["("]}]}`,
+			wantErr: "rule 1 (mount): argument 1: error parsing regexp",
+			success: false,
+		},
+		{
+			name:    "failure: no rules",
+			input:   `{"rules": []}`,
+			wantErr: "policy has no rules",
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePolicy([]byte(tt.input))
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("parsePolicy() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("parsePolicy() error = %v, want to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
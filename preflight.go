@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Geteuid and LookPath are mockable in tests.
+var (
+	Geteuid  = os.Geteuid
+	LookPath = exec.LookPath
+)
+
+// binaryPackages names the Debian package providing each binary, for the
+// fix hint of a missing binary.
+var binaryPackages = map[string]string{
+	"bash": "bash", "sudo": "sudo", "doas": "doas", "nsenter": "util-linux", "cat": "coreutils", "mkdir": "coreutils", "rm": "coreutils",
+	"fallocate": "util-linux", "losetup": "util-linux", "wipefs": "util-linux", "mkfs.ext4": "e2fsprogs",
+	"mount": "mount", "umount": "mount", "pvcreate": "lvm2", "vgcreate": "lvm2", "lvcreate": "lvm2",
+	"vgchange": "lvm2", "vgscan": "lvm2", "lvremove": "lvm2", "vgremove": "lvm2", "pvremove": "lvm2",
+}
+
+// elevationFixes is the fix hint when an elevation strategy cannot run a
+// trivial command.
+var elevationFixes = map[string]string{
+	"sudo":    "allow passwordless sudo (NOPASSWD) for this user, or run as root with -elevate none",
+	"doas":    "add a nopass rule for this user to doas.conf, or run as root with -elevate none",
+	"nsenter": "run the pod with hostPID: true and CAP_SYS_ADMIN so PID 1 is the node's init",
+}
+
+// Requirements is what a procedure needs from the environment.
+type Requirements struct {
+	Binaries     []string `json:"binaries"`
+	Devices      []string `json:"devices"`
+	Capabilities []int    `json:"capabilities"`
+	Modules      []string `json:"modules"`
+}
+
+var diskRequirements = Requirements{
+	Binaries:     []string{"bash", "mkdir", "fallocate", "mkfs.ext4", "mount", "umount", "cat", "wipefs", "rm"},
+	Devices:      []string{"/dev/loop-control"},
+	Capabilities: []int{capSysAdmin},
+	Modules:      []string{"loop"},
+}
+
+var lvmRequirements = Requirements{
+	Binaries: []string{"bash", "mkdir", "fallocate", "losetup", "pvcreate", "vgcreate", "lvcreate",
+		"vgchange", "vgscan", "mkfs.ext4", "mount", "umount", "cat", "lvremove", "vgremove", "pvremove", "rm"},
+	Devices:      []string{"/dev/loop-control", "/dev/mapper/control"},
+	Capabilities: []int{capSysAdmin, capMknod},
+	Modules:      []string{"loop", "dm_mod"},
+}
+
+// PreflightCheck is the outcome of one check; Fix says what to change when
+// it failed.
+type PreflightCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// PreflightReport collects the checks run before a procedure.
+type PreflightReport struct {
+	Procedure    string           `json:"procedure"`
+	Checks       []PreflightCheck `json:"checks"`
+	Elevation    string           `json:"elevation"`
+	Capabilities []string         `json:"capabilities"` // decoded CapEff, or CapBnd when elevating to root
+}
+
+// Failed returns the checks that did not pass.
+func (r PreflightReport) Failed() []PreflightCheck {
+	var failed []PreflightCheck
+	for _, c := range r.Checks {
+		if !c.OK {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// Err returns a *SkipError naming the failed checks, or nil.
+func (r PreflightReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	names := make([]string, len(failed))
+	for i, c := range failed {
+		names[i] = c.Name
+	}
+	return &SkipError{Reason: "preflight failed: " + strings.Join(names, ", ")}
+}
+
+// runPreflight checks req against the environment, with privileged steps
+// run through elev and audited as part of run runID. Device nodes are looked
+// up under root ("/", or elev.Root in production); the binaries where elev
+// runs the steps.
+func runPreflight(procedure string, req Requirements, root string, elev Elevation, runID string) PreflightReport {
+	r := PreflightReport{Procedure: procedure, Elevation: elev.Name}
+	add := func(c PreflightCheck) { r.Checks = append(r.Checks, c) }
+
+	euid := Geteuid()
+	switch {
+	case euid == 0:
+		add(PreflightCheck{Name: "euid", OK: true, Detail: "0 (root)"})
+	case len(elev.Prefix) == 0:
+		add(PreflightCheck{Name: "euid", Detail: fmt.Sprintf("%d (not root, and elevation is %s)", euid, elev.Name),
+			Fix: "run as root, or pass -elevate sudo or -elevate doas"})
+	default:
+		add(PreflightCheck{Name: "euid", OK: true, Detail: fmt.Sprintf("%d (not root, privileged steps use %s)", euid, elev.Name)})
+	}
+
+	// As root we keep our effective set; switching user gets the bounding set.
+	field := "CapEff"
+	if euid != 0 && elev.SwitchesUser {
+		field = "CapBnd"
+	}
+	var caps uint64
+	capsErr := fmt.Errorf("reading /proc/self/status failed")
+	if data, err := ReadFile("/proc/self/status"); err == nil {
+		caps, capsErr = parseCapMask(data, field)
+	}
+	r.Capabilities = decodeCaps(caps)
+	for _, bit := range req.Capabilities {
+		c := PreflightCheck{Name: capName(bit), OK: capsErr == nil && caps&(1<<bit) != 0}
+		switch {
+		case capsErr != nil:
+			c.Detail = capsErr.Error()
+		case c.OK:
+			c.Detail = "present in " + field
+		default:
+			c.Detail = "missing from " + field
+			c.Fix = fmt.Sprintf("add %s to securityContext.capabilities.add in pod.yaml, or run the container with --privileged",
+				strings.TrimPrefix(capName(bit), "CAP_"))
+		}
+		add(c)
+	}
+
+	binaries := req.Binaries
+	if len(elev.Prefix) > 0 {
+		binaries = append([]string{elev.Prefix[0]}, binaries...)
+	}
+	elevFound := true
+	for i, bin := range binaries {
+		c := PreflightCheck{Name: "binary " + bin}
+		lookPath, where := elev.LookPath, "in the image"
+		if len(elev.Prefix) > 0 && i == 0 {
+			lookPath = LookPath // the elevation tool itself runs in the container
+		} else if elev.Root != "" {
+			where = "on the node"
+		}
+		if path, err := lookPath(bin); err != nil {
+			c.Detail = "not found on PATH"
+			c.Fix = fmt.Sprintf("install the %s package %s", binaryPackages[bin], where)
+			if len(elev.Prefix) > 0 && i == 0 {
+				elevFound = false
+			}
+		} else {
+			c.OK, c.Detail = true, path
+		}
+		add(c)
+	}
+
+	if len(elev.Prefix) > 0 && elevFound {
+		argv := elev.Argv("true")
+		c := PreflightCheck{Name: strings.Join(argv, " ")}
+		if _, err := auditedOutput(runID, []string{"true"}, argv); err != nil {
+			c.Detail = err.Error()
+			c.Fix = elevationFixes[elev.Name]
+		} else {
+			c.OK, c.Detail = true, "works non-interactively"
+		}
+		add(c)
+	}
+
+	for _, dev := range req.Devices {
+		c := PreflightCheck{Name: dev}
+		if fileExists(filepath.Join(root, dev)) {
+			c.OK, c.Detail = true, "present"
+		} else {
+			c.Detail = "missing"
+			c.Fix = "run the container with --privileged (or privileged: true in pod.yaml) so host device nodes are visible"
+		}
+		add(c)
+	}
+	return r
+}
+
+// formatPreflight renders the report: one line when every check passed,
+// otherwise every check with a fix for each failure.
+func formatPreflight(r PreflightReport) string {
+	if len(r.Failed()) == 0 {
+		return fmt.Sprintf("Preflight for %s procedure: all %d checks passed", r.Procedure, len(r.Checks))
+	}
+	lines := []string{fmt.Sprintf("Preflight for %s procedure: %d of %d checks failed", r.Procedure, len(r.Failed()), len(r.Checks))}
+	for _, c := range r.Checks {
+		status := "ok  "
+		if !c.OK {
+			status = "FAIL"
+		}
+		line := fmt.Sprintf("  [%s] %s: %s", status, c.Name, c.Detail)
+		if c.Fix != "" {
+			line += "\n         fix: " + c.Fix
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "  capabilities: "+orNone(strings.Join(r.Capabilities, ", ")))
+	return strings.Join(lines, "\n")
+}
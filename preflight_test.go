@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================== runPreflight =====================
+func TestRunPreflight(t *testing.T) {
+	oldReadFile, oldEuid, oldLookPath, oldExec := ReadFile, Geteuid, LookPath, ExecOutput
+	defer func() { ReadFile, Geteuid, LookPath, ExecOutput = oldReadFile, oldEuid, oldLookPath, oldExec }()
+
+	const rootStatus = "Name:\tapp\nCapEff:\t000001ffffffffff\nCapBnd:\t000001ffffffffff\n"
+	const userStatus = "Name:\tapp\nCapEff:\t0000000000000000\nCapBnd:\t00000000a80425fb\n"
+	tests := []struct {
+		name        string
+		procedure   string
+		req         Requirements
+		elev        string
+		euid        int
+		status      string
+		missingBins []string
+		probeErr    error
+		devices     []string
+		wantErr     string
+		wantContain []string
+		success     bool
+	}{
+		{
+			name:        "success: privileged root container",
+			procedure:   "LVM",
+			req:         lvmRequirements,
+			elev:        "none",
+			status:      rootStatus,
+			devices:     []string{"dev/loop-control", "dev/mapper/control"},
+			wantContain: []string{"Preflight for LVM procedure: all 22 checks passed"},
+			success:     true,
+		},
+		{
+			name:        "success: root with nsenter probes the host namespace",
+			procedure:   "disk",
+			req:         diskRequirements,
+			elev:        "nsenter",
+			status:      rootStatus,
+			devices:     []string{"dev/loop-control"},
+			wantContain: []string{"Preflight for disk procedure: all 14 checks passed"},
+			success:     true,
+		},
+		{
+			name:        "failure: nsenter needs the binaries on the node",
+			procedure:   "disk",
+			req:         diskRequirements,
+			elev:        "nsenter",
+			status:      rootStatus,
+			missingBins: []string{"mkfs.ext4"},
+			devices:     []string{"dev/loop-control"},
+			wantErr:     "skipped: preflight failed: binary mkfs.ext4",
+			wantContain: []string{"[ok  ] binary nsenter: /usr/bin/nsenter", "[ok  ] binary mount: /usr/sbin/mount", "[FAIL] binary mkfs.ext4: not found on PATH\n         fix: install the e2fsprogs package on the node"},
+			success:     false,
+		},
+		{
+			name:      "failure: unprivileged container without device nodes",
+			procedure: "LVM",
+			req:       lvmRequirements,
+			elev:      "none",
+			status:    "Name:\tapp\nCapEff:\t00000000a80425fb\n",
+			devices:   []string{"dev/loop-control"},
+			wantErr:   "skipped: preflight failed: CAP_SYS_ADMIN, /dev/mapper/control",
+			wantContain: []string{
+				"Preflight for LVM procedure: 2 of 22 checks failed",
+				"  [ok  ] euid: 0 (root)",
+				"  [FAIL] CAP_SYS_ADMIN: missing from CapEff\n         fix: add SYS_ADMIN to securityContext.capabilities.add in pod.yaml",
+				"  [ok  ] CAP_MKNOD: present in CapEff",
+				"  [FAIL] /dev/mapper/control: missing\n         fix: run the container with --privileged",
+				"  capabilities: CAP_CHOWN, CAP_DAC_OVERRIDE",
+			},
+			success: false,
+		},
+		{
+			name:        "failure: non-root without passwordless sudo",
+			procedure:   "disk",
+			req:         diskRequirements,
+			elev:        "sudo",
+			euid:        1000,
+			status:      userStatus,
+			probeErr:    errors.New("exit status 1"),
+			devices:     []string{"dev/loop-control"},
+			wantErr:     "skipped: preflight failed: CAP_SYS_ADMIN, sudo -n true",
+			wantContain: []string{"euid: 1000 (not root", "CAP_SYS_ADMIN: missing from CapBnd", "[FAIL] sudo -n true: exit status 1\n         fix: allow passwordless sudo"},
+			success:     false,
+		},
+		{
+			name:        "failure: non-root without elevation",
+			procedure:   "disk",
+			req:         diskRequirements,
+			elev:        "none",
+			euid:        1000,
+			status:      userStatus,
+			devices:     []string{"dev/loop-control"},
+			wantErr:     "skipped: preflight failed: euid, CAP_SYS_ADMIN",
+			wantContain: []string{"[FAIL] euid: 1000 (not root, and elevation is none)\n         fix: run as root, or pass -elevate sudo", "CAP_SYS_ADMIN: missing from CapEff"},
+			success:     false,
+		},
+		{
+			name:        "failure: missing binaries skip the sudo probe",
+			procedure:   "disk",
+			req:         diskRequirements,
+			elev:        "sudo",
+			status:      rootStatus,
+			missingBins: []string{"sudo", "mkfs.ext4"},
+			devices:     []string{"dev/loop-control"},
+			wantErr:     "skipped: preflight failed: binary sudo, binary mkfs.ext4",
+			wantContain: []string{"[FAIL] binary mkfs.ext4: not found on PATH\n         fix: install the e2fsprogs package in the image"},
+			success:     false,
+		},
+		{
+			name:        "failure: status unreadable",
+			procedure:   "disk",
+			req:         diskRequirements,
+			elev:        "none",
+			devices:     []string{"dev/loop-control"},
+			wantErr:     "skipped: preflight failed: CAP_SYS_ADMIN",
+			wantContain: []string{"[FAIL] CAP_SYS_ADMIN: reading /proc/self/status failed", "capabilities: none"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, dev := range tt.devices {
+				path := filepath.Join(root, dev)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ReadFile = func(path string) ([]byte, error) {
+				if path == "/proc/self/status" && tt.status != "" {
+					return []byte(tt.status), nil
+				}
+				return nil, errors.New("no such file")
+			}
+			Geteuid = func() int { return tt.euid }
+			LookPath = func(file string) (string, error) {
+				for _, m := range tt.missingBins {
+					if m == file {
+						return "", errors.New("executable file not found in $PATH")
+					}
+				}
+				return "/usr/bin/" + file, nil
+			}
+			elev := elevations[tt.elev]
+			if elev.Root != "" {
+				// The steps' binaries are looked up on the node, not in the container.
+				elev.Root = root
+				t.Setenv("PATH", "/usr/sbin:/usr/bin")
+				os.MkdirAll(filepath.Join(root, "usr/sbin"), 0o755)
+			nodeBins:
+				for _, bin := range tt.req.Binaries {
+					for _, m := range tt.missingBins {
+						if m == bin {
+							continue nodeBins
+						}
+					}
+					if err := os.WriteFile(filepath.Join(root, "usr/sbin", bin), nil, 0o755); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			probe := strings.Join(elev.Argv("true"), " ")
+			probeCalled := false
+			ExecOutput = func(name string, arg ...string) ([]byte, error) {
+				probeCalled = true
+				if got := strings.Join(append([]string{name}, arg...), " "); got != probe {
+					t.Errorf("ExecOutput(%s), want %s", got, probe)
+				}
+				return nil, tt.probeErr
+			}
+
+			report := runPreflight(tt.procedure, tt.req, root, elev, "")
+			err := report.Err()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Err() = %v, want nil", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("Err() = %v, want %q", err, tt.wantErr)
+			}
+			wantProbe := len(elev.Prefix) > 0 && (len(tt.missingBins) == 0 || tt.missingBins[0] != elev.Prefix[0])
+			if probeCalled != wantProbe {
+				t.Errorf("elevation probe called = %v, want %v", probeCalled, wantProbe)
+			}
+			got := formatPreflight(report)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatPreflight() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// psiResources are the resources exposed under /proc/pressure and as
+// <resource>.pressure files in cgroup v2.
+var psiResources = []string{"cpu", "memory", "io"}
+
+// PSILine is one "some" or "full" line of a pressure file. Averages are
+// percentages; Total is the cumulative stall time in microseconds.
+type PSILine struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+// PSIResource holds both lines of a pressure file. The node-level cpu file
+// only gained a "full" line in Linux 5.13, so Full may be absent.
+type PSIResource struct {
+	Some    PSILine `json:"some"`
+	Full    PSILine `json:"full"`
+	HasFull bool    `json:"has_full"`
+}
+
+// PSISnapshot is a point-in-time reading of node and cgroup pressure, keyed
+// by resource name. Resources that couldn't be read are omitted.
+type PSISnapshot struct {
+	Time   time.Time              `json:"time"`
+	Node   map[string]PSIResource `json:"node"`
+	Cgroup map[string]PSIResource `json:"cgroup,omitempty"`
+}
+
+// parsePSI parses the content of a pressure file.
+func parsePSI(data []byte) (PSIResource, error) {
+	var res PSIResource
+	var sawSome bool
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return PSIResource{}, fmt.Errorf("unexpected pressure line %q", line)
+		}
+		var l PSILine
+		for _, kv := range fields[1:] {
+			key, val, _ := strings.Cut(kv, "=")
+			var err error
+			switch key {
+			case "avg10":
+				l.Avg10, err = strconv.ParseFloat(val, 64)
+			case "avg60":
+				l.Avg60, err = strconv.ParseFloat(val, 64)
+			case "avg300":
+				l.Avg300, err = strconv.ParseFloat(val, 64)
+			case "total":
+				l.Total, err = strconv.ParseUint(val, 10, 64)
+			default:
+				err = fmt.Errorf("unknown key %q", key)
+			}
+			if err != nil {
+				return PSIResource{}, fmt.Errorf("parsing %q: %w", line, err)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			res.Some, sawSome = l, true
+		case "full":
+			res.Full, res.HasFull = l, true
+		default:
+			return PSIResource{}, fmt.Errorf("unexpected pressure line %q", line)
+		}
+	}
+	if !sawSome {
+		return PSIResource{}, fmt.Errorf("no \"some\" line")
+	}
+	return res, nil
+}
+
+// readPSIFiles reads path(resource) for every PSI resource. Missing files are
+// normal on kernels without CONFIG_PSI or with psi=0 and are skipped silently.
+func readPSIFiles(path func(resource string) string) map[string]PSIResource {
+	out := make(map[string]PSIResource)
+	for _, r := range psiResources {
+		data, err := ReadFile(path(r))
+		if err != nil {
+			continue
+		}
+		res, err := parsePSI(data)
+		if err != nil {
+			fmt.Printf("Parsing %s failed: %v\n", path(r), err)
+			continue
+		}
+		out[r] = res
+	}
+	return out
+}
+
+// readPSISnapshot reads /proc/pressure and, if cgroupDir is set, the
+// cgroup v2 pressure files of our own cgroup.
+func readPSISnapshot(cgroupDir string) PSISnapshot {
+	snap := PSISnapshot{
+		Time: time.Now(),
+		Node: readPSIFiles(func(r string) string { return "/proc/pressure/" + r }),
+	}
+	if cgroupDir != "" {
+		snap.Cgroup = readPSIFiles(func(r string) string { return filepath.Join(cgroupDir, r+".pressure") })
+	}
+	return snap
+}
+
+func formatPSIScope(scope string, res map[string]PSIResource) []string {
+	var lines []string
+	for _, r := range psiResources {
+		p, ok := res[r]
+		if !ok {
+			continue
+		}
+		line := fmt.Sprintf("Pressure %s %s: some avg10=%.2f avg60=%.2f avg300=%.2f total=%dus",
+			scope, r, p.Some.Avg10, p.Some.Avg60, p.Some.Avg300, p.Some.Total)
+		if p.HasFull {
+			line += fmt.Sprintf("; full avg10=%.2f avg60=%.2f avg300=%.2f total=%dus",
+				p.Full.Avg10, p.Full.Avg60, p.Full.Avg300, p.Full.Total)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// formatPSISnapshot renders a snapshot for the machine info output.
+func formatPSISnapshot(snap PSISnapshot) string {
+	lines := append(formatPSIScope("node", snap.Node), formatPSIScope("cgroup", snap.Cgroup)...)
+	if len(lines) == 0 {
+		return "Pressure: not available (kernel without PSI support)"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatPSIDeltaScope(scope string, before, after map[string]PSIResource, elapsed time.Duration) []string {
+	var lines []string
+	for _, r := range psiResources {
+		b, okB := before[r]
+		a, okA := after[r]
+		if !okB || !okA || a.Some.Total < b.Some.Total {
+			continue
+		}
+		stall := time.Duration(a.Some.Total-b.Some.Total) * time.Microsecond
+		line := fmt.Sprintf("Pressure during procedure %s %s: some stalled %s (%.1f%%)",
+			scope, r, stall, float64(stall)/float64(elapsed)*100)
+		if b.HasFull && a.HasFull && a.Full.Total >= b.Full.Total {
+			full := time.Duration(a.Full.Total-b.Full.Total) * time.Microsecond
+			line += fmt.Sprintf(", full stalled %s (%.1f%%)", full, float64(full)/float64(elapsed)*100)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// formatPSIDelta reports how long tasks stalled on each resource between two
+// snapshots, as an absolute time and as a share of the elapsed wall time.
+func formatPSIDelta(before, after PSISnapshot) string {
+	elapsed := after.Time.Sub(before.Time)
+	if elapsed <= 0 {
+		return "Pressure during procedure: not available"
+	}
+	lines := append(formatPSIDeltaScope("node", before.Node, after.Node, elapsed),
+		formatPSIDeltaScope("cgroup", before.Cgroup, after.Cgroup, elapsed)...)
+	if len(lines) == 0 {
+		return "Pressure during procedure: not available"
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ===================== parsePSI =====================
+func TestParsePSI(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    PSIResource
+		wantErr bool
+		success bool
+	}{
+		{
+			name:  "success: some and full",
+			input: "some avg10=12.00 avg60=8.00 avg300=3.00 total=9000000\nfull avg10=10.00 avg60=6.50 avg300=2.50 total=7000000\n",
+			want: PSIResource{
+				Some:    PSILine{Avg10: 12, Avg60: 8, Avg300: 3, Total: 9000000},
+				Full:    PSILine{Avg10: 10, Avg60: 6.5, Avg300: 2.5, Total: 7000000},
+				HasFull: true,
+			},
+			success: true,
+		},
+		{
+			name:    "success: node cpu before 5.13 has only some",
+			input:   "some avg10=0.10 avg60=0.05 avg300=0.01 total=123456\n",
+			want:    PSIResource{Some: PSILine{Avg10: 0.1, Avg60: 0.05, Avg300: 0.01, Total: 123456}},
+			success: true,
+		},
+		{
+			name:    "failure: unknown line kind",
+			input:   "partial avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			wantErr: true,
+			success: false,
+		},
+		{
+			name:    "failure: bad number",
+			input:   "some avg10=x avg60=0.00 avg300=0.00 total=0\n",
+			wantErr: true,
+			success: false,
+		},
+		{
+			name:    "failure: missing some line",
+			input:   "full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			wantErr: true,
+			success: false,
+		},
+		{
+			name:    "failure: empty",
+			input:   "",
+			wantErr: true,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePSI([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePSI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parsePSI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ===================== readPSISnapshot =====================
+func TestReadPSISnapshot(t *testing.T) {
+	oldReadFile := ReadFile
+	defer func() { ReadFile = oldReadFile }()
+
+	nodeFiles := map[string]string{
+		"/proc/pressure/cpu":    "some avg10=2.00 avg60=1.00 avg300=0.50 total=1000\n",
+		"/proc/pressure/memory": "some avg10=0.00 avg60=0.00 avg300=0.00 total=10\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=5\n",
+		"/proc/pressure/io":     "garbage\n",
+	}
+
+	tests := []struct {
+		name       string
+		cgroupDir  string
+		nodeErr    error
+		wantNode   []string
+		wantCgroup []string
+		success    bool
+	}{
+		{
+			name:       "success: node and cgroup pressure",
+			cgroupDir:  "testdata/cgroup/v2/kubepods/pod123/ctr",
+			wantNode:   []string{"cpu", "memory"},
+			wantCgroup: []string{"cpu", "memory", "io"},
+			success:    true,
+		},
+		{
+			name:     "success: no cgroup dir (cgroup v1)",
+			wantNode: []string{"cpu", "memory"},
+			success:  true,
+		},
+		{
+			name:    "failure: kernel without PSI",
+			nodeErr: errors.New("no such file"),
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ReadFile = func(path string) ([]byte, error) {
+				if strings.HasPrefix(path, "/proc/pressure/") {
+					if tt.nodeErr != nil {
+						return nil, tt.nodeErr
+					}
+					return []byte(nodeFiles[path]), nil
+				}
+				return os.ReadFile(path)
+			}
+			snap := readPSISnapshot(tt.cgroupDir)
+			if len(snap.Node) != len(tt.wantNode) {
+				t.Errorf("node resources = %v, want %v", snap.Node, tt.wantNode)
+			}
+			for _, r := range tt.wantNode {
+				if _, ok := snap.Node[r]; !ok {
+					t.Errorf("node resource %q missing", r)
+				}
+			}
+			if len(snap.Cgroup) != len(tt.wantCgroup) {
+				t.Errorf("cgroup resources = %v, want %v", snap.Cgroup, tt.wantCgroup)
+			}
+			if got := snap.Cgroup["io"].Full.Total; tt.cgroupDir != "" && got != 7000000 {
+				t.Errorf("cgroup io full total = %d, want 7000000", got)
+			}
+		})
+	}
+}
+
+// ===================== formatPSISnapshot / formatPSIDelta =====================
+func TestFormatPSISnapshot(t *testing.T) {
+	snap := PSISnapshot{
+		Node: map[string]PSIResource{
+			"cpu": {Some: PSILine{Avg10: 1.5, Avg60: 0.75, Avg300: 0.2, Total: 4000000}},
+			"io":  {Some: PSILine{Avg10: 12, Total: 9}, Full: PSILine{Avg10: 10, Total: 7}, HasFull: true},
+		},
+	}
+	got := formatPSISnapshot(snap)
+	for _, want := range []string{
+		"Pressure node cpu: some avg10=1.50 avg60=0.75 avg300=0.20 total=4000000us",
+		"Pressure node io: some avg10=12.00 avg60=0.00 avg300=0.00 total=9us; full avg10=10.00",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatPSISnapshot() = %q, want to contain %q", got, want)
+		}
+	}
+	if got := formatPSISnapshot(PSISnapshot{}); !strings.Contains(got, "not available") {
+		t.Errorf("formatPSISnapshot(empty) = %q, want not available", got)
+	}
+}
+
+func TestFormatPSIDelta(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := PSISnapshot{
+		Time: start,
+		Node: map[string]PSIResource{
+			"io":  {Some: PSILine{Total: 1000000}, Full: PSILine{Total: 500000}, HasFull: true},
+			"cpu": {Some: PSILine{Total: 0}},
+		},
+		Cgroup: map[string]PSIResource{"memory": {Some: PSILine{Total: 0}}},
+	}
+	after := PSISnapshot{
+		Time: start.Add(10 * time.Second),
+		Node: map[string]PSIResource{
+			"io":  {Some: PSILine{Total: 3000000}, Full: PSILine{Total: 1500000}, HasFull: true},
+			"cpu": {Some: PSILine{Total: 500000}},
+		},
+		Cgroup: map[string]PSIResource{"memory": {Some: PSILine{Total: 100000}}},
+	}
+
+	tests := []struct {
+		name        string
+		before      PSISnapshot
+		after       PSISnapshot
+		wantContain []string
+		success     bool
+	}{
+		{
+			name:   "success: stall time and share of elapsed",
+			before: before,
+			after:  after,
+			wantContain: []string{
+				"Pressure during procedure node cpu: some stalled 500ms (5.0%)",
+				"Pressure during procedure node io: some stalled 2s (20.0%), full stalled 1s (10.0%)",
+				"Pressure during procedure cgroup memory: some stalled 100ms (1.0%)",
+			},
+			success: true,
+		},
+		{
+			name:        "failure: no PSI data",
+			before:      PSISnapshot{Time: start},
+			after:       PSISnapshot{Time: start.Add(time.Second)},
+			wantContain: []string{"Pressure during procedure: not available"},
+			success:     false,
+		},
+		{
+			name:        "failure: non-increasing time",
+			before:      after,
+			after:       before,
+			wantContain: []string{"Pressure during procedure: not available"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatPSIDelta(tt.before, tt.after)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatPSIDelta() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
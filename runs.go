@@ -0,0 +1,374 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Run states.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// StepResult is the outcome of one executed (or denied) step of a run.
+type StepResult struct {
+	Argv       []string `json:"argv"`
+	ExitCode   int      `json:"exit_code"`
+	DurationMS int64    `json:"duration_ms"`
+	Output     string   `json:"output,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Run is one execution of a procedure, started by the schedule or the API.
+type Run struct {
+	ID        string       `json:"id"`
+	Procedure string       `json:"procedure"`
+	Trigger   string       `json:"trigger"`
+	Status    string       `json:"status"`
+	Error     string       `json:"error,omitempty"`
+	Started   time.Time    `json:"started"`
+	Finished  *time.Time   `json:"finished,omitempty"` // nil while running
+	Steps     []StepResult `json:"steps"`
+
+	events       []RunEvent
+	seq          int           // Seq of the last event
+	outputEvents int           // step-output events emitted, kept or not
+	notify       chan struct{} // closed and replaced on every new event
+}
+
+// procedureFuncs maps procedure names, as used by the API, to their
+// functions (mockable in tests).
+var procedureFuncs = map[string]func(KernelInfo, *Recorder) error{
+	"disk": runDiskProcedure,
+	"lvm":  runLVMProcedure,
+}
+
+// procedureTitle is the name a procedure has in the output ("Disk", "LVM").
+func procedureTitle(name string) string {
+	if name == "lvm" {
+		return "LVM"
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// RunInProgressError is returned by RunManager.Start while another run holds
+// the procedure resources.
+type RunInProgressError struct {
+	Active Run
+}
+
+func (e *RunInProgressError) Error() string {
+	return fmt.Sprintf("%s run %s is in progress since %s", e.Active.Procedure, e.Active.ID, e.Active.Started.Format(time.TimeOnly))
+}
+
+// maxRuns is how many finished runs RunManager keeps for GET /v1/runs/{id}.
+const maxRuns = 100
+
+// RunManager lets one procedure run at a time, since every procedure uses the
+// same test directory, mount points and volume group, and keeps the results
+// of recent runs.
+type RunManager struct {
+	mu     sync.Mutex
+	runs   map[string]*Run
+	order  []string
+	active *Run
+}
+
+func newRunManager() *RunManager {
+	return &RunManager{runs: map[string]*Run{}}
+}
+
+// runs is the process-wide RunManager the schedule and the API share.
+var runs = newRunManager()
+
+// Start registers a new run of procedure and makes it the active one, or
+// returns a *RunInProgressError. The caller must call Finish.
+func (m *RunManager) Start(procedure, trigger string) (*Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active != nil {
+		return nil, &RunInProgressError{Active: m.copyRun(m.active)}
+	}
+	if n := len(m.order); n > 0 {
+		m.trimOutput(m.runs[m.order[n-1]])
+	}
+	run := &Run{ID: newRunID(), Procedure: procedure, Trigger: trigger, Status: RunRunning, Started: time.Now(), Steps: []StepResult{}, notify: make(chan struct{})}
+	m.runs[run.ID] = run
+	m.order = append(m.order, run.ID)
+	if len(m.order) > maxRuns {
+		delete(m.runs, m.order[0])
+		m.order = m.order[1:]
+	}
+	m.active = run
+	return run, nil
+}
+
+// Finish records the outcome of run and releases the procedure resources.
+func (m *RunManager) Finish(run *Run, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finished := time.Now()
+	run.Finished = &finished
+	var skip *SkipError
+	switch {
+	case err == nil:
+		run.Status = RunSucceeded
+	case errors.As(err, &skip):
+		run.Status, run.Error = RunSkipped, skip.Reason
+	default:
+		run.Status, run.Error = RunFailed, err.Error()
+	}
+	m.emit(run, RunEvent{Type: EventRunFinished, Status: run.Status, Error: run.Error})
+	if m.active == run {
+		m.active = nil
+	}
+}
+
+// Recorder records the steps of one run into the RunManager that started
+// it. The procedure functions pass it down to runCommand and RunArgv; a nil
+// *Recorder, for commands run outside of any run, records nothing.
+type Recorder struct {
+	m   *RunManager
+	run *Run
+}
+
+// Recorder returns the recorder of run, which m started.
+func (m *RunManager) Recorder(run *Run) *Recorder {
+	return &Recorder{m: m, run: run}
+}
+
+// RunID returns the ID of the recorded run, or "" for a nil Recorder.
+func (r *Recorder) RunID() string {
+	if r == nil {
+		return ""
+	}
+	return r.run.ID
+}
+
+// stepStarted records that the run starts a step.
+func (r *Recorder) stepStarted(argv []string) {
+	if r == nil {
+		return
+	}
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.emit(r.run, RunEvent{Type: EventStepStarted, Step: len(r.run.Steps) + 1, Argv: argv})
+}
+
+// recordOutput records one line the current step wrote.
+func (r *Recorder) recordOutput(stream, line string) {
+	if r == nil {
+		return
+	}
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.emit(r.run, RunEvent{Type: EventStepOutput, Step: len(r.run.Steps) + 1, Stream: stream, Line: line})
+}
+
+// recordStep appends a step result to the run.
+func (r *Recorder) recordStep(res StepResult) {
+	if r == nil {
+		return
+	}
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.run.Steps = append(r.run.Steps, res)
+	r.m.emit(r.run, RunEvent{Type: EventStepFinished, Step: len(r.run.Steps), Result: &res})
+}
+
+// Get returns a copy of the run with the given ID.
+func (m *RunManager) Get(id string) (Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return m.copyRun(run), true
+}
+
+func (m *RunManager) copyRun(run *Run) Run {
+	c := *run
+	c.Steps = append([]StepResult{}, run.Steps...)
+	c.events, c.notify = nil, nil
+	return c
+}
+
+// executeRun runs the procedure of a run already started in m and reports its
+// outcome, breaker status, pressure stall and CPU usage to w.
+func executeRun(w io.Writer, m *RunManager, run *Run, hostRoot string) error {
+	title := procedureTitle(run.Procedure)
+	kernel := readKernelInfo("/sys", hostRoot)
+	psiCgroupDir := cgroupPSIDir()
+	psiBefore := readPSISnapshot(psiCgroupDir)
+	statBefore, _ := readProcStat()
+	fmt.Fprintf(w, "Run ID: %s\n", run.ID)
+
+	var skip *SkipError
+	err := procedureFuncs[run.Procedure](kernel, m.Recorder(run))
+	if errors.As(err, &skip) {
+		fmt.Fprintf(w, "=== %s Procedure Skipped: %s ===\n", title, skip.Reason)
+	} else if err != nil {
+		fmt.Fprintln(w, err)
+	} else {
+		fmt.Fprintf(w, "=== %s Procedure Completed Successfully ===\n\n", title)
+	}
+	m.Finish(run, err)
+	if snapshot, ok := m.Get(run.ID); ok {
+		recordHistory(HistoryRecord{Time: *snapshot.Finished, Kind: HistoryRun, Run: &snapshot})
+	}
+	breaker := procedureBreaker(title)
+	breaker.Record(time.Now(), err)
+	recordProcedureRun(title, time.Now(), err)
+	fmt.Fprintln(w, formatBreakerStatus(title, breaker.Status()))
+
+	fmt.Fprintln(w, formatPSIDelta(psiBefore, readPSISnapshot(psiCgroupDir)))
+	if statAfter, err := readProcStat(); err == nil {
+		if usage := formatProcedureCPUUsage(statBefore, statAfter); usage != "" {
+			fmt.Fprintln(w, usage)
+		}
+	}
+	return err
+}
+
+// RunAPI serves on-demand procedure runs. Runs it starts bypass the circuit
+// breaker, but their outcome is recorded in it like any other run.
+type RunAPI struct {
+	runs     *RunManager
+	hostRoot string
+
+	mu     sync.Mutex
+	closed bool           // set by Wait; no more runs are started
+	wg     sync.WaitGroup // runs still executing
+}
+
+func newRunAPI(runs *RunManager, hostRoot string) *RunAPI {
+	return &RunAPI{runs: runs, hostRoot: hostRoot}
+}
+
+// apiError is the JSON body of a failed API request.
+type apiError struct {
+	Error     string `json:"error"`
+	ActiveRun *Run   `json:"active_run,omitempty"`
+}
+
+// requireToken wraps h so that every request must send "Authorization:
+// Bearer <token>"; an empty token lets every request through.
+func requireToken(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, apiError{Error: "missing or invalid bearer token"})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// readAPIToken reads the bearer token from path; an empty path means no
+// token.
+func readAPIToken(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return token, nil
+}
+
+// checkAPIAddr refuses to serve the API, which runs privileged procedures,
+// beyond loopback without a token.
+func checkAPIAddr(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host == "localhost" || ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s is not a loopback address; set -api-token-file to serve the API on it", addr)
+}
+
+// Register adds POST /v1/procedures/{name}/runs, GET /v1/runs/{id} and
+// GET /v1/runs/{id}/events to mux.
+func (a *RunAPI) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /v1/procedures/{name}/runs", a.startRun)
+	mux.HandleFunc("GET /v1/runs/{id}", a.getRun)
+	mux.HandleFunc("GET /v1/runs/{id}/events", a.streamEvents)
+}
+
+func (a *RunAPI) startRun(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := procedureFuncs[name]; !ok {
+		names := make([]string, 0, len(procedureFuncs))
+		for n := range procedureFuncs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown procedure %q (want one of %s)", name, strings.Join(names, ", "))})
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		writeJSON(w, http.StatusServiceUnavailable, apiError{Error: "shutting down"})
+		return
+	}
+	run, err := a.runs.Start(name, "api")
+	var busy *RunInProgressError
+	if errors.As(err, &busy) {
+		writeJSON(w, http.StatusConflict, apiError{Error: err.Error(), ActiveRun: &busy.Active})
+		return
+	}
+	snapshot, _ := a.runs.Get(run.ID)
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		executeRun(jobOutput, a.runs, run, a.hostRoot)
+	}()
+	w.Header().Set("Location", "/v1/runs/"+run.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// Wait stops the API from starting runs and blocks until every run it
+// started has finished.
+func (a *RunAPI) Wait() {
+	a.mu.Lock()
+	a.closed = true
+	a.mu.Unlock()
+	a.wg.Wait()
+}
+
+func (a *RunAPI) getRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := a.runs.Get(r.PathValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("run %q not found", r.PathValue("id"))})
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ===================== RunManager =====================
+func TestRunManager(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+		wantError  string
+		success    bool
+	}{
+		{name: "success: run succeeded", err: nil, wantStatus: RunSucceeded, success: true},
+		{name: "success: skip keeps its reason", err: &SkipError{Reason: "kernel module dm_mod is not available"}, wantStatus: RunSkipped, wantError: "kernel module dm_mod is not available", success: true},
+		{name: "failure: run failed", err: errors.New("command failed"), wantStatus: RunFailed, wantError: "command failed", success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newRunManager()
+			run, err := m.Start("lvm", "api")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var busy *RunInProgressError
+			if _, err := m.Start("disk", "schedule"); !errors.As(err, &busy) || busy.Active.ID != run.ID {
+				t.Fatalf("second Start() error = %v, want run %s in progress", err, run.ID)
+			}
+			m.Recorder(run).recordStep(StepResult{Argv: []string{"pvcreate", "-y", "/dev/loop0"}})
+			m.Finish(run, tt.err)
+
+			got, ok := m.Get(run.ID)
+			if !ok {
+				t.Fatalf("Get(%s) not found", run.ID)
+			}
+			if got.Status != tt.wantStatus || got.Error != tt.wantError || len(got.Steps) != 1 || got.Finished == nil {
+				t.Errorf("run = %+v, want status %s, error %q and one step", got, tt.wantStatus, tt.wantError)
+			}
+			if _, err := m.Start("disk", "schedule"); err != nil {
+				t.Errorf("Start() after Finish error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRunManager_KeepsRecentRuns(t *testing.T) {
+	m := newRunManager()
+	var first string
+	for i := 0; i < maxRuns+1; i++ {
+		run, err := m.Start("disk", "schedule")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = run.ID
+		}
+		m.Finish(run, nil)
+	}
+	if _, ok := m.Get(first); ok {
+		t.Errorf("oldest run %s still kept after %d runs", first, maxRuns+1)
+	}
+	if len(m.runs) != maxRuns {
+		t.Errorf("kept %d runs, want %d", len(m.runs), maxRuns)
+	}
+}
+
+// ===================== RunAPI =====================
+func TestRunAPI(t *testing.T) {
+	oldFuncs, oldRun, oldOutput, oldPolicy := procedureFuncs, RunArgv, jobOutput, policy
+	defer func() {
+		procedureFuncs, RunArgv, jobOutput, policy = oldFuncs, oldRun, oldOutput, oldPolicy
+	}()
+	defer func() {
+		breakersMu.Lock()
+		breakers = map[string]*Breaker{}
+		breakersMu.Unlock()
+	}()
+	resetProcedureHealths(t)
+
+	jobOutput, policy = io.Discard, nil
+	RunArgv = func(argv []string, _ *Recorder) error { return nil }
+	release := make(chan struct{})
+	procedureFuncs = map[string]func(KernelInfo, *Recorder) error{
+		"lvm": func(_ KernelInfo, r *Recorder) error {
+			<-release
+			return runCommands([]Step{privCmd("pvcreate", "-y", "/dev/loop0")}, r)
+		},
+	}
+	m := newRunManager()
+	api := newRunAPI(m, "")
+	defer api.Wait() // even if the test fails before release is closed
+	mux := http.NewServeMux()
+	api.Register(mux)
+	do := func(method, path string, v any) int {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, path, rec.Body.String(), err)
+		}
+		return rec.Code
+	}
+
+	var started Run
+	if code := do(http.MethodPost, "/v1/procedures/lvm/runs", &started); code != http.StatusAccepted || started.Status != RunRunning || started.ID == "" {
+		t.Fatalf("POST lvm = %d %+v, want 202 running", code, started)
+	}
+	var running map[string]any
+	if do(http.MethodGet, "/v1/runs/"+started.ID, &running); running["status"] != RunRunning {
+		t.Errorf("GET running run = %v, want status running", running)
+	} else if _, ok := running["finished"]; ok {
+		t.Errorf("running run has finished = %v, want no finished field", running["finished"])
+	}
+	var conflict apiError
+	if code := do(http.MethodPost, "/v1/procedures/lvm/runs", &conflict); code != http.StatusConflict || conflict.ActiveRun == nil || conflict.ActiveRun.ID != started.ID {
+		t.Errorf("second POST = %d %+v, want 409 naming run %s", code, conflict, started.ID)
+	}
+	var unknown apiError
+	if code := do(http.MethodPost, "/v1/procedures/raid/runs", &unknown); code != http.StatusNotFound || !strings.Contains(unknown.Error, `unknown procedure "raid" (want one of lvm)`) {
+		t.Errorf("POST raid = %d %+v, want 404", code, unknown)
+	}
+	if code := do(http.MethodGet, "/v1/runs/nope", &unknown); code != http.StatusNotFound {
+		t.Errorf("GET unknown run = %d, want 404", code)
+	}
+
+	close(release)
+	api.Wait()
+	var got Run
+	if code := do(http.MethodGet, "/v1/runs/"+started.ID, &got); code != http.StatusOK {
+		t.Fatalf("GET run = %d", code)
+	}
+	var closed apiError
+	if code := do(http.MethodPost, "/v1/procedures/lvm/runs", &closed); code != http.StatusServiceUnavailable {
+		t.Errorf("POST after Wait = %d %+v, want 503", code, closed)
+	}
+	if got.Status != RunSucceeded || got.Finished == nil || got.Trigger != "api" || len(got.Steps) != 1 || got.Steps[0].Argv[0] != "pvcreate" {
+		t.Errorf("finished run = %+v, want succeeded with the pvcreate step", got)
+	}
+}
+
+// ===================== API access =====================
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		header   string
+		wantCode int
+		success  bool
+	}{
+		{name: "success: no token configured", token: "", header: "", wantCode: http.StatusOK, success: true},
+		{name: "success: matching bearer token", token: "s3cret", header: "Bearer s3cret", wantCode: http.StatusOK, success: true},
+		{name: "failure: missing header", token: "s3cret", header: "", wantCode: http.StatusUnauthorized, success: false},
+		{name: "failure: wrong token", token: "s3cret", header: "Bearer guess", wantCode: http.StatusUnauthorized, success: false},
+		{name: "failure: token without scheme", token: "s3cret", header: "s3cret", wantCode: http.StatusUnauthorized, success: false},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/procedures/lvm/runs", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			requireToken(tt.token, ok).ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if !tt.success && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q, want Bearer", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestCheckAPIAddr(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		token   string
+		success bool
+	}{
+		{name: "success: IPv4 loopback", addr: "127.0.0.1:9091", success: true},
+		{name: "success: IPv6 loopback", addr: "[::1]:9091", success: true},
+		{name: "success: localhost", addr: "localhost:9091", success: true},
+		{name: "success: any address with a token", addr: ":9091", token: "s3cret", success: true},
+		{name: "failure: all interfaces without a token", addr: ":9091", success: false},
+		{name: "failure: pod IP without a token", addr: "10.0.0.5:9091", success: false},
+		{name: "failure: no port", addr: "127.0.0.1", success: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkAPIAddr(tt.addr, tt.token); (err == nil) != tt.success {
+				t.Errorf("checkAPIAddr(%q) error = %v, want success %v", tt.addr, err, tt.success)
+			}
+		})
+	}
+}
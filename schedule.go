@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Clock is the time source of the scheduler; tests substitute a fake one.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// realClock uses the wall clock and the mockable Sleep.
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { Sleep(d) }
+
+// randInt63n is mockable in tests.
+var randInt63n = rand.Int63n
+
+// Scheduling modes. Fixed-rate starts iterations on a fixed grid anchored at
+// the first run, so long iterations don't push later ones back; fixed-delay
+// waits Interval after each iteration ends.
+const (
+	FixedRate  = "fixed-rate"
+	FixedDelay = "fixed-delay"
+)
+
+// Schedule says when a job runs: every Interval according to Mode, or at
+// the times matching Cron when it is set. Each iteration is delayed by a
+// random amount in [0, Jitter) so a fleet started together spreads out.
+// MaxIterations 0 runs forever. OnMissed, if set, is told how many scheduled
+// runs were skipped because an iteration was still running.
+type Schedule struct {
+	Interval      time.Duration
+	Cron          *CronExpr
+	Jitter        time.Duration
+	MaxIterations int
+	Mode          string
+	OnMissed      func(iteration, missed int)
+}
+
+// Validate reports flag values the scheduler can't work with.
+func (s Schedule) Validate() error {
+	switch {
+	case s.Cron == nil && s.Interval <= 0:
+		return fmt.Errorf("interval must be positive, got %s", s.Interval)
+	case s.Jitter < 0:
+		return fmt.Errorf("jitter must not be negative, got %s", s.Jitter)
+	case s.MaxIterations < 0:
+		return fmt.Errorf("max iterations must not be negative, got %d", s.MaxIterations)
+	case s.Mode != FixedRate && s.Mode != FixedDelay:
+		return fmt.Errorf("unknown mode %q (want %s or %s)", s.Mode, FixedRate, FixedDelay)
+	}
+	return nil
+}
+
+func (s Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(randInt63n(int64(s.Jitter)))
+}
+
+// runSchedule calls run with iteration numbers 1, 2, ... as s dictates and
+// returns the number of iterations run. Interval schedules run right away,
+// cron schedules at their first matching minute.
+func runSchedule(s Schedule, clock Clock, run func(iteration int)) int {
+	next := clock.Now()
+	if s.Cron != nil {
+		next = s.Cron.Next(next)
+	}
+	for i := 1; ; i++ {
+		if next.IsZero() {
+			return i - 1 // the cron expression never matches again
+		}
+		if d := next.Add(s.jitter()).Sub(clock.Now()); d > 0 {
+			clock.Sleep(d)
+		}
+		run(i)
+		if s.MaxIterations > 0 && i >= s.MaxIterations {
+			return i
+		}
+
+		now := clock.Now()
+		missed := 0
+		switch {
+		case s.Cron != nil:
+			// Cron times an overrunning iteration missed are dropped.
+			for next = s.Cron.Next(next); !next.IsZero() && next.Before(now); next = s.Cron.Next(next) {
+				missed++
+			}
+		case s.Mode == FixedDelay:
+			next = now.Add(s.Interval)
+		default:
+			// Deadlines are computed from the first run, not from when the
+			// previous sleep ended, so they don't drift. Deadlines an
+			// overrunning iteration missed are dropped, as time.Ticker does.
+			next = next.Add(s.Interval)
+			if next.Before(now) {
+				n := now.Sub(next)/s.Interval + 1
+				missed = int(n)
+				next = next.Add(n * s.Interval)
+			}
+		}
+		if missed > 0 && s.OnMissed != nil {
+			s.OnMissed(i, missed)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock advances only when the scheduler sleeps or a test iteration
+// "works".
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+// ===================== runSchedule =====================
+func TestRunSchedule(t *testing.T) {
+	oldRand := randInt63n
+	defer func() { randInt63n = oldRand }()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		sched      Schedule
+		work       []time.Duration // how long each iteration takes
+		jitter     time.Duration   // what randInt63n returns
+		wantStarts []time.Duration // iteration start times relative to start
+		wantMissed int
+		success    bool
+	}{
+		{
+			name:       "success: fixed-rate keeps the grid despite work",
+			sched:      Schedule{Interval: 15 * time.Second, MaxIterations: 4, Mode: FixedRate},
+			work:       []time.Duration{3 * time.Second, 14 * time.Second, 0, 0},
+			wantStarts: []time.Duration{0, 15 * time.Second, 30 * time.Second, 45 * time.Second},
+			success:    true,
+		},
+		{
+			name:       "success: fixed-delay waits after work",
+			sched:      Schedule{Interval: 15 * time.Second, MaxIterations: 3, Mode: FixedDelay},
+			work:       []time.Duration{3 * time.Second, 14 * time.Second, 0},
+			wantStarts: []time.Duration{0, 18 * time.Second, 47 * time.Second},
+			success:    true,
+		},
+		{
+			name:       "success: fixed-rate skips slots an overrun missed",
+			sched:      Schedule{Interval: 10 * time.Second, MaxIterations: 3, Mode: FixedRate},
+			work:       []time.Duration{25 * time.Second, 0, 0},
+			wantStarts: []time.Duration{0, 30 * time.Second, 40 * time.Second},
+			wantMissed: 2,
+			success:    true,
+		},
+		{
+			name:       "success: jitter delays every iteration without accumulating",
+			sched:      Schedule{Interval: 10 * time.Second, Jitter: 5 * time.Second, MaxIterations: 3, Mode: FixedRate},
+			work:       []time.Duration{0, 0, 0},
+			jitter:     2 * time.Second,
+			wantStarts: []time.Duration{2 * time.Second, 12 * time.Second, 22 * time.Second},
+			success:    true,
+		},
+		{
+			name:       "success: cron waits for its first match and drops missed times",
+			sched:      Schedule{Cron: mustParseCron(t, "*/15 * * * *"), MaxIterations: 3, Mode: FixedDelay},
+			work:       []time.Duration{20 * time.Minute, 0, 0},
+			wantStarts: []time.Duration{15 * time.Minute, 45 * time.Minute, time.Hour},
+			wantMissed: 1,
+			success:    true,
+		},
+		{
+			name:       "success: once runs a single iteration",
+			sched:      Schedule{Interval: time.Hour, MaxIterations: 1, Mode: FixedDelay},
+			work:       []time.Duration{time.Minute},
+			wantStarts: []time.Duration{0},
+			success:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			randInt63n = func(n int64) int64 {
+				if n != int64(tt.sched.Jitter) {
+					t.Errorf("randInt63n(%d), want %d", n, tt.sched.Jitter)
+				}
+				return int64(tt.jitter)
+			}
+			clock := &fakeClock{now: start}
+			missed := 0
+			tt.sched.OnMissed = func(_, n int) { missed += n }
+			var starts []time.Duration
+			n := runSchedule(tt.sched, clock, func(i int) {
+				starts = append(starts, clock.now.Sub(start))
+				clock.now = clock.now.Add(tt.work[i-1])
+			})
+			if n != len(tt.wantStarts) {
+				t.Errorf("runSchedule() = %d iterations, want %d", n, len(tt.wantStarts))
+			}
+			if !reflect.DeepEqual(starts, tt.wantStarts) {
+				t.Errorf("iterations started at %v, want %v", starts, tt.wantStarts)
+			}
+			if missed != tt.wantMissed {
+				t.Errorf("missed = %d, want %d", missed, tt.wantMissed)
+			}
+		})
+	}
+}
+
+// ===================== Schedule.Validate =====================
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		sched   Schedule
+		wantErr string
+		success bool
+	}{
+		{
+			name:    "success: defaults",
+			sched:   Schedule{Interval: 15 * time.Second, Mode: FixedDelay},
+			success: true,
+		},
+		{
+			name:    "failure: zero interval",
+			sched:   Schedule{Mode: FixedRate},
+			wantErr: "interval must be positive",
+			success: false,
+		},
+		{
+			name:    "failure: negative jitter",
+			sched:   Schedule{Interval: time.Second, Jitter: -time.Second, Mode: FixedRate},
+			wantErr: "jitter must not be negative",
+			success: false,
+		},
+		{
+			name:    "failure: unknown mode",
+			sched:   Schedule{Interval: time.Second, Mode: "cron"},
+			wantErr: `unknown mode "cron"`,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sched.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Validate() unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
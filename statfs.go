@@ -0,0 +1,14 @@
+package main
+
+// FSStats holds the statfs(2) fields we report, in units of BlockSize.
+type FSStats struct {
+	BlockSize uint64
+	Blocks    uint64
+	Bfree     uint64
+	Bavail    uint64
+	Files     uint64
+	Ffree     uint64
+}
+
+// Statfs is mockable in tests.
+var Statfs = statfs
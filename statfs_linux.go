@@ -0,0 +1,23 @@
+package main
+
+import "syscall"
+
+func statfs(path string) (FSStats, error) {
+	var s syscall.Statfs_t
+	if err := syscall.Statfs(path, &s); err != nil {
+		return FSStats{}, err
+	}
+	// f_frsize is the unit of the block counts; older kernels leave it 0.
+	bsize := uint64(s.Frsize)
+	if bsize == 0 {
+		bsize = uint64(s.Bsize)
+	}
+	return FSStats{
+		BlockSize: bsize,
+		Blocks:    uint64(s.Blocks),
+		Bfree:     uint64(s.Bfree),
+		Bavail:    uint64(s.Bavail),
+		Files:     uint64(s.Files),
+		Ffree:     uint64(s.Ffree),
+	}, nil
+}
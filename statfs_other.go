@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func statfs(path string) (FSStats, error) {
+	return FSStats{}, fmt.Errorf("statfs %s: not supported on %s", path, runtime.GOOS)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RunArgv runs one command without a shell (mockable in tests).
+var RunArgv = runArgv
+
+// runArgv streams every line the command writes to stdout or stderr into
+// the run r records as it arrives; the combined output is kept for the error.
+func runArgv(argv []string, r *Recorder) error {
+	c := exec.Command(argv[0], argv[1:]...)
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return &CommandError{Argv: argv, Err: err}
+	}
+	stderr, err := c.StderrPipe()
+	if err != nil {
+		return &CommandError{Argv: argv, Err: err}
+	}
+	if err := c.Start(); err != nil {
+		return &CommandError{Argv: argv, Err: err}
+	}
+
+	var (
+		mu  sync.Mutex
+		out strings.Builder
+		wg  sync.WaitGroup
+	)
+	read := func(stream string, pipe io.Reader) {
+		defer wg.Done()
+		br := bufio.NewReader(pipe)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				mu.Lock()
+				out.WriteString(line)
+				mu.Unlock()
+				r.recordOutput(stream, strings.TrimSuffix(line, "\n"))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go read("stdout", stdout)
+	go read("stderr", stderr)
+	wg.Wait() // the pipes must be drained before Wait closes them
+	if err := c.Wait(); err != nil {
+		return &CommandError{Argv: argv, Output: out.String(), Err: err}
+	}
+	return nil
+}
+
+// CommandError is a step that ran (or failed to start) and did not succeed.
+type CommandError struct {
+	Argv   []string
+	Output string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command failed: %s\nOutput:\n%s", shellJoin(e.Argv), e.Output)
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// exitCode returns the exit status behind a step error: 0 for success and
+// -1 when the command didn't run to completion (not found, killed, mocked).
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Step is one command of a procedure. Argv is executed directly, so values
+// spliced into it (home directory, loop device) are never parsed by a shell.
+// A shell step sets Script instead; it runs as bash -c Script with Argv as
+// the positional parameters $1, $2, ..., so data still stays out of the
+// script text. Privileged steps are elevated by runCommand according to the
+// selected Elevation.
+type Step struct {
+	Argv       []string `json:"argv,omitempty"`
+	Script     string   `json:"script,omitempty"`
+	Privileged bool     `json:"privileged,omitempty"`
+}
+
+// cmd is a step that runs argv with our own privileges.
+func cmd(argv ...string) Step {
+	return Step{Argv: argv}
+}
+
+// privCmd is a step that runs argv with elevated privileges.
+func privCmd(argv ...string) Step {
+	return Step{Argv: argv, Privileged: true}
+}
+
+// shellCmd opts into a shell for what argv can't express (redirections,
+// pipes). args are available to script as "$1", "$2", ...
+func shellCmd(script string, args ...string) Step {
+	return Step{Script: script, Argv: args}
+}
+
+// privShellCmd is shellCmd with elevated privileges.
+func privShellCmd(script string, args ...string) Step {
+	return Step{Script: script, Argv: args, Privileged: true}
+}
+
+// commandArgv returns the argv of step before elevation; this is what the
+// policy checks.
+func commandArgv(step Step) []string {
+	if step.Script != "" {
+		// $0 is "bash" so error messages from the script read naturally.
+		return append([]string{"bash", "-c", step.Script, "bash"}, step.Argv...)
+	}
+	return step.Argv
+}
+
+// stepArgv returns the argv runCommand executes for step.
+func stepArgv(step Step) []string {
+	if step.Privileged || elevation.AllSteps {
+		return elevation.Argv(commandArgv(step)...)
+	}
+	return commandArgv(step)
+}
+
+// shellSafe matches words that mean the same to bash quoted or not.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s as a single word for bash.
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellJoin renders argv as a bash command line that runs the same argv.
+// It is used for display, and for the rare script that must embed a value.
+func shellJoin(argv []string) string {
+	words := make([]string, len(argv))
+	for i, a := range argv {
+		words[i] = shellQuote(a)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hostileHomeDirs break or subvert a command when spliced into shell text.
+var hostileHomeDirs = []string{
+	"/home/with space",
+	"/home/x; rm -rf /",
+	"/home/$(touch pwned)",
+	"/home/`id`",
+	"/home/it's",
+	"/home/new\nline",
+	"/home/--help",
+}
+
+// ===================== shellQuote / shellJoin =====================
+func TestShellJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		argv    []string
+		want    string
+		success bool
+	}{
+		{
+			name:    "success: plain words stay bare",
+			argv:    []string{"lvcreate", "-l", "50%FREE", "-n", "testlv1", "testvg"},
+			want:    "lvcreate -l 50%FREE -n testlv1 testvg",
+			success: true,
+		},
+		{
+			name:    "success: metacharacters and quotes are quoted",
+			argv:    []string{"mkdir", "-p", "/home/it's; rm -rf /", ""},
+			want:    `mkdir -p '/home/it'\''s; rm -rf /' ''`,
+			success: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shellJoin(tt.argv); got != tt.want {
+				t.Errorf("shellJoin() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellJoin_RoundTripsThroughBash(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	argv := append([]string{"printf", `%s\0`}, hostileHomeDirs...)
+	out, err := exec.Command("bash", "-c", shellJoin(argv)).Output()
+	if err != nil {
+		t.Fatalf("bash -c %q: %v", shellJoin(argv), err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	if !reflect.DeepEqual(got, hostileHomeDirs) {
+		t.Errorf("bash saw %q, want %q", got, hostileHomeDirs)
+	}
+}
+
+// ===================== lvmCommands / diskCommands with hostile home dirs =====================
+func TestCommands_HostileHomeDir(t *testing.T) {
+	for _, home := range hostileHomeDirs {
+		testDir := filepath.Join(home, "file_systems_test")
+		diskFile := filepath.Join(testDir, "disk1")
+		procedures := map[string][]Step{
+			"disk": diskCommands(home),
+			"lvm":  lvmCommands(home, "/dev/loop0"),
+		}
+		for name, steps := range procedures {
+			t.Run(name+" "+home, func(t *testing.T) {
+				for _, step := range steps {
+					if strings.Contains(step.Script, home) {
+						t.Errorf("home dir spliced into script %q", step.Script)
+					}
+					for _, arg := range step.Argv {
+						if strings.Contains(arg, home) && arg != testDir && arg != diskFile {
+							t.Errorf("argument %q of %q is not a whole path", arg, step.Argv)
+						}
+					}
+				}
+				if got := steps[0].Argv; !reflect.DeepEqual(got, []string{"mkdir", "-p", testDir}) {
+					t.Errorf("first step = %q, want mkdir -p %q", got, testDir)
+				}
+			})
+		}
+	}
+}
+
+func TestInnerLVMProcedure_HostileHomeDir(t *testing.T) {
+	oldRun := RunArgv
+	defer func() { RunArgv = oldRun }()
+
+	home := "/home/x; touch /tmp/pwned"
+	var got [][]string
+	RunArgv = func(argv []string, _ *Recorder) error {
+		got = append(got, argv)
+		return nil
+	}
+	err := innerLVMProcedure(
+		func() (string, error) { return home, nil },
+		func() (string, error) { return "/dev/loop7", nil },
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("innerLVMProcedure() unexpected error: %v", err)
+	}
+	want := []string{"rm", "-rf", "/mnt/lvm1", "/mnt/lvm2", home + "/file_systems_test"}
+	if last := got[len(got)-1]; !reflect.DeepEqual(last, want) {
+		t.Errorf("last command = %q, want %q", last, want)
+	}
+}
+
+// ===================== runArgv (real execution) =====================
+func TestRunArgv_HostileArguments(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	tmp := t.TempDir()
+	pwned := filepath.Join(tmp, "pwned")
+	dir := filepath.Join(tmp, "a; touch "+pwned+" $(touch "+pwned+")")
+	out := filepath.Join(dir, "out")
+
+	steps := []Step{
+		cmd("mkdir", "-p", dir),
+		shellCmd(`printf '%s' "$1" > "$2"`, dir, out),
+	}
+	for _, step := range steps {
+		if err := runArgv(stepArgv(step), nil); err != nil {
+			t.Fatalf("runArgv(%q): %v", stepArgv(step), err)
+		}
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != dir {
+		t.Errorf("out = %q, %v, want %q", data, err, dir)
+	}
+	if fileExists(pwned) {
+		t.Error("hostile directory name was executed by a shell")
+	}
+}
+
+// ===================== testHomeDir =====================
+func TestTestHomeDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		home    string
+		wantErr bool
+		success bool
+	}{
+		{
+			name:    "success: absolute path with spaces and metacharacters",
+			home:    "/home/x; rm -rf /",
+			success: true,
+		},
+		{
+			name:    "failure: relative path that reads as an option",
+			home:    "-rf",
+			wantErr: true,
+			success: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", tt.home)
+			got, err := testHomeDir()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("testHomeDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.home {
+				t.Errorf("testHomeDir() = %q, want %q", got, tt.home)
+			}
+		})
+	}
+}
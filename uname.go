@@ -0,0 +1,13 @@
+package main
+
+// UnameInfo mirrors the fields of struct utsname returned by uname(2).
+type UnameInfo struct {
+	Sysname  string `json:"sysname"`
+	Nodename string `json:"nodename"`
+	Release  string `json:"release"`
+	Version  string `json:"version"`
+	Machine  string `json:"machine"`
+}
+
+// Uname is mockable in tests.
+var Uname = uname
@@ -0,0 +1,30 @@
+package main
+
+import "syscall"
+
+func uname() (UnameInfo, error) {
+	var u syscall.Utsname
+	if err := syscall.Uname(&u); err != nil {
+		return UnameInfo{}, err
+	}
+	return UnameInfo{
+		Sysname:  utsString(u.Sysname[:]),
+		Nodename: utsString(u.Nodename[:]),
+		Release:  utsString(u.Release[:]),
+		Version:  utsString(u.Version[:]),
+		Machine:  utsString(u.Machine[:]),
+	}, nil
+}
+
+// utsString converts a NUL-terminated utsname field; its element type is
+// int8 or uint8 depending on the architecture.
+func utsString[T int8 | uint8](field []T) string {
+	b := make([]byte, 0, len(field))
+	for _, c := range field {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
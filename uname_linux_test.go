@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+// ===================== utsString =====================
+func TestUtsString(t *testing.T) {
+	if got := utsString([]int8{'6', '.', '1', 0, 'x'}); got != "6.1" {
+		t.Errorf("utsString(int8) = %q, want %q", got, "6.1")
+	}
+	if got := utsString([]uint8{'x', '8', '6'}); got != "x86" {
+		t.Errorf("utsString(uint8) = %q, want %q", got, "x86")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func uname() (UnameInfo, error) {
+	return UnameInfo{}, fmt.Errorf("uname is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Getenv is mockable in tests.
+var Getenv = os.Getenv
+
+// cgroupRuntimes maps substrings of /proc/self/cgroup paths to the container
+// runtime that creates them. Kubernetes is reported separately.
+var cgroupRuntimes = []struct{ marker, runtime string }{
+	{"cri-containerd-", "containerd"},
+	{"/containerd/", "containerd"},
+	{"crio-", "cri-o"},
+	{"libpod-", "podman"},
+	{"/docker/", "docker"},
+	{"docker-", "docker"},
+	{"/lxc/", "lxc"},
+	{"lxc.payload", "lxc"},
+}
+
+// RuntimeEnv describes what we are running on and inside. Sources name the
+// evidence behind each conclusion so odd results can be traced.
+type RuntimeEnv struct {
+	Hypervisor        string   `json:"hypervisor,omitempty"`
+	HypervisorSources []string `json:"hypervisor_sources,omitempty"`
+	Container         string   `json:"container,omitempty"`
+	ContainerSources  []string `json:"container_sources,omitempty"`
+	Kubernetes        bool     `json:"kubernetes"`
+	KubernetesSources []string `json:"kubernetes_sources,omitempty"`
+	CapEff            uint64   `json:"cap_eff"`
+	CapLastCap        int      `json:"cap_last_cap"`
+	Privileged        bool     `json:"privileged"`
+}
+
+// cgroupRuntime looks for runtime-specific names in /proc/self/cgroup paths.
+// With a private cgroup namespace the paths are "/" and reveal nothing.
+func cgroupRuntime(data []byte) (runtime string, kubernetes bool) {
+	for _, path := range parseProcCgroup(data) {
+		if strings.Contains(path, "kubepods") {
+			kubernetes = true
+		}
+		for _, r := range cgroupRuntimes {
+			if runtime == "" && strings.Contains(path, r.marker) {
+				runtime = r.runtime
+			}
+		}
+	}
+	return runtime, kubernetes
+}
+
+// readRuntimeEnv detects the hypervisor, container runtime and privilege
+// level. root is the filesystem root of the container ("/" in production);
+// cpu and dmi come from the CPU and DMI collectors.
+func readRuntimeEnv(root, sysRoot string, cpu CPUInfo, dmi DMIInfo) RuntimeEnv {
+	var env RuntimeEnv
+
+	// Hypervisor: the most specific name wins, but every hint is listed.
+	if t := readSysfsString(filepath.Join(sysRoot, "hypervisor/type")); t != "" {
+		env.Hypervisor = t
+		env.HypervisorSources = append(env.HypervisorSources, "/sys/hypervisor/type")
+	}
+	if dmi.VirtualMachine != "" {
+		if env.Hypervisor == "" {
+			env.Hypervisor = dmi.VirtualMachine
+		}
+		env.HypervisorSources = append(env.HypervisorSources, "DMI product")
+	}
+	if cpu.HasFlag("hypervisor") {
+		if env.Hypervisor == "" {
+			env.Hypervisor = "unknown"
+		}
+		env.HypervisorSources = append(env.HypervisorSources, "cpuinfo hypervisor flag")
+	}
+
+	// Container runtime.
+	setContainer := func(runtime, source string) {
+		if env.Container == "" {
+			env.Container = runtime
+		}
+		env.ContainerSources = append(env.ContainerSources, source)
+	}
+	if fileExists(filepath.Join(root, ".dockerenv")) {
+		setContainer("docker", "/.dockerenv")
+	}
+	if fileExists(filepath.Join(root, "run/.containerenv")) {
+		setContainer("podman", "/run/.containerenv")
+	}
+	if name := readSysfsString(filepath.Join(root, "run/systemd/container")); name != "" {
+		setContainer(name, "/run/systemd/container")
+	}
+	if data, err := ReadFile("/proc/self/cgroup"); err == nil {
+		runtime, kube := cgroupRuntime(data)
+		if runtime != "" {
+			setContainer(runtime, "cgroup path")
+		}
+		if kube {
+			env.KubernetesSources = append(env.KubernetesSources, "kubepods cgroup")
+		}
+	}
+	if Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		env.KubernetesSources = append(env.KubernetesSources, "KUBERNETES_SERVICE_HOST")
+	}
+	env.Kubernetes = len(env.KubernetesSources) > 0
+
+	// Privilege: a privileged container keeps every capability the kernel knows.
+	env.CapLastCap = readCapLastCap()
+	if data, err := ReadFile("/proc/self/status"); err == nil {
+		if eff, err := parseCapMask(data, "CapEff"); err == nil {
+			env.CapEff = eff
+			env.Privileged = eff&allCaps(env.CapLastCap) == allCaps(env.CapLastCap)
+		}
+	}
+	return env
+}
+
+// formatRuntimeEnv renders the runtime environment for the machine info output.
+func formatRuntimeEnv(env RuntimeEnv) string {
+	virt := "none detected (bare metal or hidden hypervisor)"
+	if env.Hypervisor != "" {
+		virt = fmt.Sprintf("%s (%s)", env.Hypervisor, strings.Join(env.HypervisorSources, ", "))
+	}
+	container := "none detected"
+	if env.Container != "" {
+		container = fmt.Sprintf("%s (%s)", env.Container, strings.Join(env.ContainerSources, ", "))
+	}
+	if env.Kubernetes {
+		container += fmt.Sprintf(", Kubernetes pod (%s)", strings.Join(env.KubernetesSources, ", "))
+	}
+	privileged := "no"
+	if env.Privileged {
+		privileged = "yes"
+	}
+	return strings.Join([]string{
+		"Virtualization: " + virt,
+		"Container runtime: " + container,
+		fmt.Sprintf("Privileged: %s (CapEff=%016x, %d of %d capabilities)",
+			privileged, env.CapEff, bits.OnesCount64(env.CapEff&allCaps(env.CapLastCap)), env.CapLastCap+1),
+	}, "\n")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ===================== cgroupRuntime =====================
+func TestCgroupRuntime(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantRuntime string
+		wantKube    bool
+		success     bool
+	}{
+		{
+			name:        "success: containerd under kubepods (v2)",
+			input:       "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1.slice/cri-containerd-abc123.scope\n",
+			wantRuntime: "containerd",
+			wantKube:    true,
+			success:     true,
+		},
+		{
+			name:        "success: plain docker (v1)",
+			input:       "12:memory:/docker/0123456789ab\n11:cpu,cpuacct:/docker/0123456789ab\n",
+			wantRuntime: "docker",
+			success:     true,
+		},
+		{
+			name:    "success: private cgroup namespace reveals nothing",
+			input:   "0::/\n",
+			success: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runtime, kube := cgroupRuntime([]byte(tt.input))
+			if runtime != tt.wantRuntime || kube != tt.wantKube {
+				t.Errorf("cgroupRuntime() = %q, %v, want %q, %v", runtime, kube, tt.wantRuntime, tt.wantKube)
+			}
+		})
+	}
+}
+
+// ===================== readRuntimeEnv =====================
+func TestReadRuntimeEnv(t *testing.T) {
+	oldReadFile, oldGetenv := ReadFile, Getenv
+	defer func() { ReadFile, Getenv = oldReadFile, oldGetenv }()
+
+	const privilegedStatus = "Name:\tapp\nCapEff:\t000001ffffffffff\n"
+	tests := []struct {
+		name        string
+		rootFiles   []string
+		files       map[string]string
+		env         map[string]string
+		cpu         CPUInfo
+		dmi         DMIInfo
+		wantContain []string
+		wantPriv    bool
+		success     bool
+	}{
+		{
+			name:      "success: kind pod on a KVM guest",
+			rootFiles: []string{".dockerenv"},
+			files: map[string]string{
+				"/proc/self/cgroup":             "0::/kubepods/besteffort/pod1/cri-containerd-abc\n",
+				"/proc/sys/kernel/cap_last_cap": "40\n",
+			},
+			env: map[string]string{"KUBERNETES_SERVICE_HOST": "10.96.0.1"},
+			cpu: CPUInfo{Flags: []string{"fpu", "hypervisor"}},
+			dmi: DMIInfo{Available: true, VirtualMachine: "KVM"},
+			wantContain: []string{
+				"Virtualization: KVM (DMI product, cpuinfo hypervisor flag)",
+				"Container runtime: docker (/.dockerenv, cgroup path), Kubernetes pod (kubepods cgroup, KUBERNETES_SERVICE_HOST)",
+				"Privileged: no (CapEff=00000000a80425fb, 14 of 41 capabilities)",
+			},
+			success: true,
+		},
+		{
+			name:      "success: privileged podman container on Xen",
+			rootFiles: []string{"run/.containerenv"},
+			files: map[string]string{
+				"testdata/sys/hypervisor/type":  "xen\n",
+				"/proc/sys/kernel/cap_last_cap": "40\n",
+				"/proc/self/status":             privilegedStatus,
+			},
+			wantContain: []string{
+				"Virtualization: xen (/sys/hypervisor/type)",
+				"Container runtime: podman (/run/.containerenv)",
+				"Privileged: yes (CapEff=000001ffffffffff, 41 of 41 capabilities)",
+			},
+			wantPriv: true,
+			success:  true,
+		},
+		{
+			name:  "success: bare metal, no container, cap_last_cap unreadable",
+			files: map[string]string{"/proc/self/status": privilegedStatus},
+			wantContain: []string{
+				"Virtualization: none detected",
+				"Container runtime: none detected",
+				"Privileged: yes",
+			},
+			wantPriv: true,
+			success:  true,
+		},
+		{
+			name:        "failure: status unreadable",
+			files:       map[string]string{"/proc/self/status": ""},
+			wantContain: []string{"Privileged: no (CapEff=0000000000000000"},
+			success:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tt.rootFiles {
+				path := filepath.Join(root, f)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ReadFile = func(path string) ([]byte, error) {
+				if data, ok := tt.files[path]; ok {
+					if data == "" {
+						return nil, errors.New("permission denied")
+					}
+					return []byte(data), nil
+				}
+				if path == "/proc/self/status" {
+					return os.ReadFile("testdata/proc/status")
+				}
+				return nil, errors.New("no such file")
+			}
+			Getenv = func(key string) string { return tt.env[key] }
+
+			env := readRuntimeEnv(root, "testdata/sys", tt.cpu, tt.dmi)
+			if env.Privileged != tt.wantPriv {
+				t.Errorf("Privileged = %v, want %v", env.Privileged, tt.wantPriv)
+			}
+			got := formatRuntimeEnv(env)
+			for _, want := range tt.wantContain {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatRuntimeEnv() = %q, want to contain %q", got, want)
+				}
+			}
+		})
+	}
+}